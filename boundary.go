@@ -0,0 +1,71 @@
+package sensfilter
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	boundStart uint8 = 1 << iota // 单词的第一个字符需要位于单词边界
+	boundEnd                     // 单词的最后一个字符需要位于单词边界
+)
+
+// noSpaceScripts 不使用空格分隔单词的文字，这些文字中的字符总是可以作为单词边界
+var noSpaceScripts = []*unicode.RangeTable{
+	unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar,
+}
+
+// isASCII 返回 runes 是否全部是 ASCII 字符
+func isASCII(runes []rune) bool {
+	for _, r := range runes {
+		if r >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordRune 返回 r 是否是组成单词的字符（拉丁、西里尔等字母、数字、组合符号和下划线），
+// 参照 Unicode 分词规则（UAX #29），汉字、假名等不使用空格分词的文字不算在内
+func isWordRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_'
+	}
+	if unicode.In(r, noSpaceScripts...) {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r)
+}
+
+// wordBound 返回归一化后的单词 runes 需要检查单词边界的边，首尾字符是组成单词的字符时才需要检查
+func wordBound(runes []rune) (bound uint8) {
+	if isWordRune(runes[0]) {
+		bound |= boundStart
+	}
+	if isWordRune(runes[len(runes)-1]) {
+		bound |= boundEnd
+	}
+	return
+}
+
+// matchedBound 返回匹配到的原始文本 matched 需要检查单词边界的边
+func matchedBound(matched []byte) uint8 {
+	first, _ := utf8.DecodeRune(matched)
+	last, _ := utf8.DecodeLastRune(matched)
+	return wordBound([]rune{first, last})
+}
+
+// atBoundary 返回 s 中从 start 到 end（包含）的匹配是否位于单词边界，bound 为需要检查的边
+func atBoundary(s []byte, start, end int, bound uint8) bool {
+	if bound&boundStart != 0 && start > 0 {
+		if r, _ := utf8.DecodeLastRune(s[:start]); isWordRune(r) {
+			return false
+		}
+	}
+	if bound&boundEnd != 0 && end+1 < len(s) {
+		if r, _ := utf8.DecodeRune(s[end+1:]); isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package sensfilter
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//go:embed data/t2s.txt
+var t2sData string
+
+//go:embed data/pinyin.txt
+var pinyinData string
+
+// maxPinyinVariants 一个单词最多生成的拼音变体数量，避免多音字较多的长单词生成过多的变体
+const maxPinyinVariants = 64
+
+var (
+	t2sOnce  sync.Once
+	t2sTable map[rune]rune // 繁体字到简体字的映射表，第一次使用时从 t2sData 中加载
+
+	pinyinOnce  sync.Once
+	pinyinTable map[rune][]string // 汉字到拼音的映射表，第一次使用时从 pinyinData 中加载
+)
+
+// t2sRune 返回繁体字 r 对应的简体字，r 不是繁体字时原样返回
+func t2sRune(r rune) rune {
+	if r < 0x3400 { // 映射表中的字符都不小于 CJK 扩展 A 区的起点
+		return r
+	}
+	t2sOnce.Do(func() {
+		t2sTable = loadRuneTable(t2sData)
+	})
+	if s, ok := t2sTable[r]; ok {
+		return s
+	}
+	return r
+}
+
+// loadRuneTable 解析每行格式为 "字符\t字符" 的映射表，忽略空行和以 # 开头的注释行
+func loadRuneTable(data string) map[rune]rune {
+	table := make(map[rune]rune, strings.Count(data, "\n"))
+	for _, line := range strings.Split(data, "\n") {
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		from, to, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		k, _ := utf8.DecodeRuneInString(from)
+		v, _ := utf8.DecodeRuneInString(to)
+		table[k] = v
+	}
+	return table
+}
+
+// pinyinOf 返回汉字 r 的不带声调的全部读音，第一个为常用读音，r 没有拼音时返回 nil
+func pinyinOf(r rune) []string {
+	if r < 0x3007 {
+		return nil
+	}
+	pinyinOnce.Do(func() {
+		pinyinTable = loadPinyinTable(pinyinData)
+	})
+	return pinyinTable[r]
+}
+
+// loadPinyinTable 解析每行格式为 "汉字\t拼音[,拼音...]" 的拼音表，忽略空行和以 # 开头的注释行
+func loadPinyinTable(data string) map[rune][]string {
+	table := make(map[rune][]string, strings.Count(data, "\n"))
+	for _, line := range strings.Split(data, "\n") {
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		from, to, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		k, _ := utf8.DecodeRuneInString(from)
+		table[k] = strings.Split(to, ",")
+	}
+	return table
+}
+
+// pinyinVariants 将单词 runes 中的汉字替换为拼音，按多音字的读音组合生成所有拼音形式，最多 maxPinyinVariants 个，
+// 第一个总是全部使用常用读音的形式。单词中没有汉字时返回 nil。
+func pinyinVariants(runes []rune) [][]rune {
+	choices := make([][]string, len(runes)) // 每个位置可选的拼音，nil 表示保留原字符
+	han := false
+	for i, r := range runes {
+		if choices[i] = pinyinOf(r); choices[i] != nil {
+			han = true
+		}
+	}
+	if !han {
+		return nil
+	}
+	return combineReadings(runes, choices)
+}
+
+// abbreviations 生成全部由汉字组成的单词 runes 的拼音首字母缩写（如 "他妈的" 生成 "tmd"），多音字展开所有首字母，
+// 最多 maxPinyinVariants 个。单词字数少于 minLen 或者包含没有拼音的字符时返回 nil。
+func abbreviations(runes []rune, minLen int) [][]rune {
+	if len(runes) < minLen {
+		return nil
+	}
+	choices := make([][]string, len(runes)) // 每个位置可选的首字母
+	for i, r := range runes {
+		readings := pinyinOf(r)
+		if readings == nil {
+			return nil
+		}
+		for _, reading := range readings {
+			initial := reading[:1]
+			if !containsString(choices[i], initial) {
+				choices[i] = append(choices[i], initial)
+			}
+		}
+	}
+	return combineReadings(runes, choices)
+}
+
+// combineReadings 将 runes 中每个位置替换为 choices 中对应的可选读音，按组合生成所有形式，最多 maxPinyinVariants 个，
+// choices 中为 nil 的位置保留原字符。
+func combineReadings(runes []rune, choices [][]string) (variants [][]rune) {
+	idx := make([]int, len(runes)) // 每个位置当前使用的读音下标，像里程表一样逐个进位
+	for len(variants) < maxPinyinVariants {
+		variant := make([]rune, 0, len(runes)*3)
+		for i, r := range runes {
+			if choices[i] == nil {
+				variant = append(variant, r)
+			} else {
+				variant = append(variant, []rune(choices[i][idx[i]])...)
+			}
+		}
+		variants = append(variants, variant)
+
+		i := len(idx) - 1
+		for ; i >= 0; i-- {
+			if idx[i]+1 < len(choices[i]) {
+				idx[i]++
+				break
+			}
+			idx[i] = 0
+		}
+		if i < 0 {
+			break
+		}
+	}
+	return
+}
+
+// containsString 返回 list 中是否包含 s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,396 @@
+package sensfilter
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ComboKind 表示组合规则的类型
+type ComboKind uint8
+
+const (
+	ComboAll  ComboKind = iota // 所有单词都出现，顺序不限
+	ComboAny                   // 至少出现 N 个不同的单词，顺序不限
+	ComboNear                  // 所有单词按顺序出现
+)
+
+// ComboRule 表示一条组合规则，单独出现时无害的单词（如 "出售" 和 "枪支"）在一定范围内一起出现时才算作敏感内容。
+// 组合规则需要扫描完整的文本后才能确定，流式扫描器 Scanner 不会匹配组合规则
+type ComboRule struct {
+	Name   string    // 规则名称，作为结果中的 Word，为空时使用规则的文本写法
+	Words  []string  // 组成规则的单词，与敏感词一样会忽略跳过字符并做归一化处理，不需要出现在敏感词中
+	Kind   ComboKind // 规则类型
+	N      int       // ComboAny 规则至少需要出现的不同单词数，为 0 时为 1
+	Within int       // 从第一个单词的起点到最后一个单词的终点最多包含的字符（rune）数，0 表示不限制
+}
+
+// String 返回规则的文本写法，如 "出售&枪支~20"、"出售|枪支|弹药/2"、"出售>枪支"，写法说明见 ParseComboRule
+func (r ComboRule) String() string {
+	sep := map[ComboKind]string{ComboAll: "&", ComboAny: "|", ComboNear: ">"}[r.Kind]
+	s := strings.Join(r.Words, sep)
+	if r.Kind == ComboAny && r.N > 1 {
+		s += "/" + strconv.Itoa(r.N)
+	}
+	if r.Within > 0 {
+		s += "~" + strconv.Itoa(r.Within)
+	}
+	return s
+}
+
+// ParseComboRule 解析一条文本写法的组合规则：单词之间用 "&" 分隔表示所有单词都出现，用 "|" 分隔表示出现任意一个，
+// 其后可以跟 "/N" 表示至少出现 N 个，用 ">" 分隔表示按顺序出现；最后可以跟 "~W" 表示所有单词出现在 W 个字符之内。
+// 例如 "出售&枪支~20"、"出售|枪支|弹药/2~30"、"出售>枪支~20"，规则名称为去除首尾空白后的整行文本。
+func ParseComboRule(line string) (rule ComboRule, err error) {
+	text := strings.TrimSpace(line)
+	rule.Name = text
+	if i := strings.LastIndexByte(text, '~'); i >= 0 {
+		if rule.Within, err = strconv.Atoi(text[i+1:]); err != nil || rule.Within <= 0 {
+			return rule, errors.New("invalid combo window: " + line)
+		}
+		text = text[:i]
+	}
+	if i := strings.LastIndexByte(text, '/'); i >= 0 && strings.Contains(text, "|") {
+		if rule.N, err = strconv.Atoi(text[i+1:]); err != nil || rule.N <= 0 {
+			return rule, errors.New("invalid combo count: " + line)
+		}
+		text = text[:i]
+	}
+
+	kinds := 0
+	for _, k := range []struct {
+		sep  string
+		kind ComboKind
+	}{{"&", ComboAll}, {"|", ComboAny}, {">", ComboNear}} {
+		if strings.Contains(text, k.sep) {
+			rule.Kind = k.kind
+			rule.Words = strings.Split(text, k.sep)
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return rule, errors.New("invalid combo rule: " + line)
+	}
+	for i, w := range rule.Words {
+		rule.Words[i] = strings.TrimSpace(w)
+	}
+	return rule, nil
+}
+
+// comboRule 是编译之后的组合规则
+type comboRule struct {
+	ComboRule
+	ids      []int // 规则中每个单词的编号
+	need     int   // 需要出现的不同单词数
+	distinct int   // 规则中不同单词的数量
+}
+
+// comboSet 保存搜索器中的所有组合规则
+type comboSet struct {
+	words []string      // 每个编号对应的单词
+	ids   map[*trie]int // 单词（及其拼音变体）结尾节点对应的编号
+	rules []*comboRule
+}
+
+// comboHit 表示组合规则中的单词在原始文本中的一次出现，start、end 为起止字节位置（包含）
+type comboHit struct {
+	id         int
+	node       *trie // 匹配到的结尾节点，单词同样是敏感词时从中取得敏感词的元数据
+	start, end int
+}
+
+// AddCombo 添加一条组合规则，规则中的单词会写入trie树但不会单独作为敏感词输出。
+// 满足规则时输出一个覆盖所有单词的结果，Word 为规则名称，Parts 为组成该结果的各个单词，Replace 时只替换这些单词。
+// 组合规则只对 Find、FindAll、FindTo 等一次搜索完整文本的方法生效，Scanner 不会匹配组合规则。
+// 组合规则的结果不参与 MatchStrategy 的选择，在任何规则下都与敏感词的结果一起返回，可能与其重叠，
+// 例如规则中的单词同样是敏感词时，Find 会同时返回该敏感词和组合规则的结果，此时 Parts 中的该单词同样带有敏感词的元数据。
+func (_this *Search) AddCombo(rule ComboRule) error {
+	if err := _this.addCombo(rule); err != nil {
+		return err
+	}
+	_this.trieWriter.BuildFail()
+	return nil
+}
+
+// AddCombos 添加多条组合规则，遇到无效的规则时返回错误，之前的规则已经添加
+func (_this *Search) AddCombos(rules []ComboRule) (err error) {
+	for _, rule := range rules {
+		if err = _this.addCombo(rule); err != nil {
+			break
+		}
+	}
+	_this.trieWriter.BuildFail()
+	return
+}
+
+// ComboStrings 添加多条文本写法的组合规则，写法说明见 ParseComboRule，空行会被忽略
+func (_this *Search) ComboStrings(lines []string) error {
+	rules := make([]ComboRule, 0, len(lines))
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		rule, err := ParseComboRule(line)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+	}
+	return _this.AddCombos(rules)
+}
+
+// ComboFile 添加文件中文本写法的组合规则（每行一条）
+func (_this *Search) ComboFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	return _this.ComboStrings(lines)
+}
+
+// ComboNetwork 添加网页 pageUrl 中文本写法的组合规则（每行一条）
+func (_this *Search) ComboNetwork(pageUrl string) error {
+	data, err := fetchNetwork(pageUrl)
+	if err != nil {
+		return err
+	}
+	return _this.ComboStrings(strings.Split(string(data), "\n"))
+}
+
+// ComboMySQL 添加数据库表 conf.TableName 中 word 字段文本写法的组合规则
+func (_this *Search) ComboMySQL(conf *DatabaseConf) error {
+	words, err := queryMySQL(conf)
+	if err != nil {
+		return err
+	}
+	lines := make([]string, len(words))
+	for i, w := range words {
+		lines[i] = w.Word
+	}
+	return _this.ComboStrings(lines)
+}
+
+// addCombo 编译组合规则并将其中的单词写入trie树，不构建失败指针
+func (_this *Search) addCombo(rule ComboRule) error {
+	if len(rule.Words) == 0 {
+		return errors.New("empty combo rule")
+	}
+	if _this.combos == nil {
+		_this.combos = &comboSet{ids: map[*trie]int{}}
+	}
+	if rule.Kind == ComboAny && rule.N > len(rule.Words) {
+		return errors.New("combo count exceeds words: " + rule.String())
+	}
+	set, writer := _this.combos, _this.trieWriter
+	c := &comboRule{ComboRule: rule}
+	if c.Name == "" {
+		c.Name = rule.String()
+	}
+	for _, word := range rule.Words {
+		runes, _ := writer.normalize([]byte(word))
+		if len(runes) == 0 {
+			return errors.New("empty word in combo rule: " + c.Name)
+		}
+		node := writer.insertRunes(runes)
+		id, ok := set.ids[node]
+		if !ok {
+			id = len(set.words)
+			set.words = append(set.words, word)
+			node.combo = true
+			set.ids[node] = id
+			writer.insertPinyin(runes, func(n *trie) {
+				if !n.combo {
+					n.combo = true
+					set.ids[n] = id
+				}
+			})
+		}
+		if c.index(id) < 0 {
+			c.distinct++
+		}
+		c.ids = append(c.ids, id)
+	}
+	c.need = c.distinct // 重复的单词只需要出现一次
+	if rule.Kind == ComboAny {
+		c.need = 1
+		if rule.N > 1 {
+			c.need = rule.N
+		}
+	}
+	set.rules = append(set.rules, c)
+	return nil
+}
+
+// findCombos 根据组合规则中单词的所有出现 hits 找出满足组合规则的结果，同一条规则的结果互不重叠
+func (_this *Search) findCombos(s []byte, hits []comboHit) (list []*Result) {
+	if len(hits) == 0 {
+		return nil
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].start != hits[j].start {
+			return hits[i].start < hits[j].start
+		}
+		return hits[i].end < hits[j].end
+	})
+	for _, rule := range _this.combos.rules {
+		last := -1 // 上一个结果的结束位置
+		for i := range hits {
+			if hits[i].start <= last {
+				continue
+			}
+			var parts []comboHit
+			if rule.Kind == ComboNear {
+				parts = rule.near(s, hits[i:])
+			} else {
+				parts = rule.window(s, hits[i:])
+			}
+			if parts == nil {
+				continue
+			}
+			res := _this.comboResult(s, rule, parts)
+			list = append(list, res)
+			last = res.End
+		}
+	}
+	return
+}
+
+// within 返回从 start 到 end（包含）的文本是否在规则限制的字符数之内
+func (c *comboRule) within(s []byte, start, end int) bool {
+	return c.Within <= 0 || end-start+1 <= c.Within || utf8.RuneCount(s[start:end+1]) <= c.Within
+}
+
+// window 返回以 hits[0] 开头、满足 ComboAll 或 ComboAny 规则的单词组合，不满足时返回nil
+func (c *comboRule) window(s []byte, hits []comboHit) []comboHit {
+	first := hits[0]
+	if c.index(first.id) < 0 {
+		return nil
+	}
+	parts := []comboHit{first}
+	seen := map[int]bool{first.id: true}
+	for _, h := range hits[1:] {
+		if len(parts) == c.need {
+			break
+		}
+		if !c.within(s, first.start, h.start) { // 之后的单词起点更晚，不可能再满足
+			break
+		}
+		if seen[h.id] || c.index(h.id) < 0 || h.start <= parts[len(parts)-1].end || !c.within(s, first.start, h.end) {
+			continue
+		}
+		seen[h.id] = true
+		parts = append(parts, h)
+	}
+	if len(parts) < c.need {
+		return nil
+	}
+	return parts
+}
+
+// near 返回以 hits[0] 开头、按顺序满足 ComboNear 规则的单词组合，不满足时返回nil
+func (c *comboRule) near(s []byte, hits []comboHit) []comboHit {
+	if hits[0].id != c.ids[0] {
+		return nil
+	}
+	parts := []comboHit{hits[0]}
+	for k := 1; k < len(c.ids); k++ {
+		prev, found := parts[len(parts)-1], false
+		for _, h := range hits { // 取起点在前一个单词之后、结束最早的出现
+			if h.id != c.ids[k] || h.start <= prev.end || (found && h.end >= parts[k].end) {
+				continue
+			}
+			if !found {
+				parts = append(parts, h)
+				found = true
+			} else {
+				parts[k] = h
+			}
+		}
+		if !found || !c.within(s, parts[0].start, parts[k].end) {
+			return nil
+		}
+	}
+	return parts
+}
+
+// index 返回编号为 id 的单词在规则中的下标，不在规则中时返回-1
+func (c *comboRule) index(id int) int {
+	for i, v := range c.ids {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// comboResult 将满足组合规则的单词组合转换为覆盖所有单词的结果
+func (_this *Search) comboResult(s []byte, rule *comboRule, parts []comboHit) *Result {
+	sort.Slice(parts, func(i, j int) bool { return parts[i].start < parts[j].start })
+	start, end := parts[0].start, parts[0].end
+	res := &Result{Word: rule.Name, Origin: rule.Name, Start: start}
+	for _, p := range parts {
+		if p.end > end {
+			end = p.end
+		}
+		if p.node.end { // 同样是敏感词的单词与单独匹配到时的结果一样带有元数据
+			res.Parts = append(res.Parts, newResult(p.node, s[p.start:p.end+1], p.start, p.end))
+			continue
+		}
+		res.Parts = append(res.Parts, &Result{
+			Word:    _this.combos.words[p.id],
+			Matched: string(s[p.start : p.end+1]),
+			Start:   p.start,
+			End:     p.end,
+			Origin:  _this.combos.words[p.id],
+		})
+	}
+	res.End = end
+	res.Matched = string(s[start : end+1])
+	return res
+}
+
+// sortResults 将结果按起点排序，起点相同时保持原来的顺序，用于将最后写入的组合规则的结果排到对应的位置
+func sortResults(list []*Result) {
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Start < list[j].Start })
+}
+
+// replaceSpans 返回替换时需要替换的互不重叠的字节区间（包含结束位置），组合规则的结果只替换其中的单词
+func replaceSpans(results []*Result) (spans [][2]int) {
+	for _, r := range results {
+		if len(r.Parts) == 0 {
+			spans = append(spans, [2]int{r.Start, r.End})
+			continue
+		}
+		for _, p := range r.Parts {
+			spans = append(spans, [2]int{p.Start, p.End})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	n := 0
+	for _, sp := range spans { // 合并重叠的区间
+		if n > 0 && sp[0] <= spans[n-1][1] {
+			if sp[1] > spans[n-1][1] {
+				spans[n-1][1] = sp[1]
+			}
+			continue
+		}
+		spans[n] = sp
+		n++
+	}
+	return spans[:n]
+}
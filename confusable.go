@@ -0,0 +1,37 @@
+package sensfilter
+
+import (
+	_ "embed"
+	"golang.org/x/text/unicode/norm"
+	"sync"
+	"unicode/utf8"
+)
+
+//go:embed data/confusables.txt
+var confusablesData string
+
+var (
+	confusablesOnce  sync.Once
+	confusablesTable map[rune]rune // 形近字到原型字符的映射表，第一次使用时从 confusablesData 中加载
+)
+
+// skeletonRune 返回 r 的形近字原型（如西里尔字母 а、数学字母 𝐟、带圈字母 ⓕ 分别对应 a、f、f），没有原型时原样返回。
+// 先按兼容分解（NFKD）展开为单个字符，再查 UTS #39 的形近字映射表。
+func skeletonRune(r rune) rune {
+	if r >= utf8.RuneSelf {
+		var buf [utf8.UTFMax]byte
+		n := utf8.EncodeRune(buf[:], r)
+		if d := norm.NFKD.Properties(buf[:n]).Decomposition(); d != nil {
+			if c, l := utf8.DecodeRune(d); l == len(d) { // 只使用分解为单个字符的兼容映射
+				r = c
+			}
+		}
+	}
+	confusablesOnce.Do(func() {
+		confusablesTable = loadRuneTable(confusablesData)
+	})
+	if p, ok := confusablesTable[r]; ok {
+		return p
+	}
+	return r
+}
@@ -0,0 +1,70 @@
+package sensfilter
+
+import (
+	"unicode/utf8"
+)
+
+// ContextRule 表示附加在敏感词上的上下文例外规则，匹配到敏感词后在其前后指定字符数内查找 Text
+type ContextRule struct {
+	Text    string // 需要查找的文本，与敏感词一样会忽略跳过字符并做归一化处理
+	Before  int    // 在匹配之前多少个字符内查找，0表示不在之前查找
+	After   int    // 在匹配之后多少个字符内查找，0表示不在之后查找
+	Require bool   // 为true时必须找到 Text 才保留匹配，为false时找到 Text 就丢弃匹配
+}
+
+// contextRule 是归一化之后的上下文例外规则
+type contextRule struct {
+	ContextRule
+	runes []rune // 归一化后的 Text
+}
+
+// compileRule 按当前的跳过字符和归一化处理编译上下文例外规则，并记录所有规则中最大的 Before
+func (t *TrieWriter) compileRule(rule ContextRule) contextRule {
+	if rule.Before > t.maxBefore {
+		t.maxBefore = rule.Before
+	}
+	runes, _ := t.normalize([]byte(rule.Text))
+	return contextRule{rule, runes}
+}
+
+// checkContext 检查 s 中从 start 到 end（包含）的匹配是否满足所有的上下文例外规则，返回是否保留该匹配
+func (_this *Search) checkContext(s []byte, start, end int, rules []contextRule) bool {
+	for _, rule := range rules {
+		found := false
+		if rule.Before > 0 {
+			i := start
+			for n := 0; n < rule.Before && i > 0; n++ { // 向前数 Before 个字符
+				_, l := utf8.DecodeLastRune(s[:i])
+				i -= l
+			}
+			found = _this.containsRunes(s[i:start], rule.runes)
+		}
+		if !found && rule.After > 0 {
+			j := end + 1
+			for n := 0; n < rule.After && j < len(s); n++ { // 向后数 After 个字符
+				_, l := utf8.DecodeRune(s[j:])
+				j += l
+			}
+			found = _this.containsRunes(s[end+1:j], rule.runes)
+		}
+		if found != rule.Require {
+			return false
+		}
+	}
+	return true
+}
+
+// containsRunes 返回归一化之后的 s 中是否包含归一化之后的文本 sub
+func (_this *Search) containsRunes(s []byte, sub []rune) bool {
+	runes, _ := _this.trieWriter.normalize(s)
+	for i := 0; i+len(sub) <= len(runes); i++ {
+		j := 0
+		for j < len(sub) && runes[i+j] == sub[j] {
+			j++
+		}
+		if j == len(sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package sensfilter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// 以下方法支持 GBK、GB18030、Big5 等非 UTF-8 编码的词库和文本，enc 可以使用 golang.org/x/text/encoding 中的编码，
+// 如 simplifiedchinese.GBK、simplifiedchinese.GB18030、traditionalchinese.Big5。
+// 词库中含有无法解码的字节的行会被跳过，并在 Report 中记录为 SkipInvalidUTF8；
+// 待搜索的文本会先转换为 UTF-8 再搜索，其中无法解码的字节按 U+FFFD 处理。
+
+var errDecode = errors.New("cannot decode character")
+
+// transcoded 表示转换为 UTF-8 的文本以及每个字节对应的原始文本中的字符位置
+type transcoded struct {
+	text         []byte // 转换后的 UTF-8 文本
+	starts, ends []int  // text 中每个字节所属的字符在原始文本中的起止字节位置（包含）
+}
+
+// transcode 使用编码 enc 逐个字符将 s 转换为 UTF-8，并记录每个字符在 s 中的位置
+func transcode(s []byte, enc encoding.Encoding) (*transcoded, error) {
+	t := &transcoded{
+		text:   make([]byte, 0, len(s)*3/2),
+		starts: make([]int, 0, len(s)*3/2),
+		ends:   make([]int, 0, len(s)*3/2),
+	}
+	decoder := enc.NewDecoder()
+	var dst [16]byte
+	for i := 0; i < len(s); {
+		nDst, nSrc := 0, 0
+		for k := 1; nSrc == 0; k++ { // 每次只给出能解码出一个字符的最少字节
+			if k > 4 || i+k > len(s) {
+				return nil, errDecode
+			}
+			var err error
+			decoder.Reset()
+			nDst, nSrc, err = decoder.Transform(dst[:], s[i:i+k], i+k == len(s))
+			if nSrc == 0 && err != transform.ErrShortSrc {
+				if err == nil {
+					err = errDecode
+				}
+				return nil, err
+			}
+		}
+		t.text = append(t.text, dst[:nDst]...)
+		for j := 0; j < nDst; j++ {
+			t.starts = append(t.starts, i)
+			t.ends = append(t.ends, i+nSrc-1)
+		}
+		i += nSrc
+	}
+	return t, nil
+}
+
+// strictDecoder 逐个字符将编码为 enc 的词库转换为 UTF-8，无法解码的字符（解码器输出的 U+FFFD）转换为非法的 UTF-8 字节 0xFF，
+// 加载时该行会作为 SkipInvalidUTF8 被跳过，原文中本来就是 U+FFFD 的字符不受影响
+type strictDecoder struct {
+	dec         transform.Transformer
+	replacement []byte // U+FFFD 在原始编码中的表示，不能表示时为nil
+}
+
+// newDecoder 返回将编码为 enc 的词库转换为 UTF-8 的转换器
+func newDecoder(enc encoding.Encoding) transform.Transformer {
+	if enc == encoding.Nop {
+		return transform.Nop
+	}
+	replacement, err := enc.NewEncoder().Bytes([]byte(string(utf8.RuneError)))
+	if err != nil {
+		replacement = nil
+	}
+	return &strictDecoder{dec: enc.NewDecoder(), replacement: replacement}
+}
+
+func (d *strictDecoder) Reset() {
+	d.dec.Reset()
+}
+
+func (d *strictDecoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	var buf [16]byte
+	for nSrc < len(src) {
+		n, m := 0, 0
+		for k := 1; m == 0; k++ { // 与 transcode 一样每次只给出能解码出一个字符的最少字节
+			if k > 4 {
+				return nDst, nSrc, errDecode
+			}
+			if nSrc+k > len(src) {
+				return nDst, nSrc, transform.ErrShortSrc
+			}
+			d.dec.Reset()
+			n, m, err = d.dec.Transform(buf[:], src[nSrc:nSrc+k], atEOF && nSrc+k == len(src))
+			if m == 0 && err != transform.ErrShortSrc {
+				if err == nil {
+					err = errDecode
+				}
+				return nDst, nSrc, err
+			}
+		}
+		out := buf[:n]
+		if bytes.Contains(out, []byte(string(utf8.RuneError))) && !bytes.Equal(src[nSrc:nSrc+m], d.replacement) {
+			out = []byte{0xFF}
+		}
+		if nDst+len(out) > len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		nDst += copy(dst[nDst:], out)
+		nSrc += m
+	}
+	return nDst, nSrc, nil
+}
+
+// remap 将结果中 UTF-8 文本的字节位置转换为原始文本中的字节位置
+func (t *transcoded) remap(res *Result) {
+	res.Start, res.End = t.starts[res.Start], t.ends[res.End]
+	for _, p := range res.Parts {
+		t.remap(p)
+	}
+}
+
+// FindEncoding 在编码为 enc 的文本 s 中搜索敏感词，结果中的 Start、End 为原始编码中的字节位置，Matched 为转换为 UTF-8 后的文本
+func (_this *Search) FindEncoding(s []byte, enc encoding.Encoding) ([]*Result, error) {
+	_, list, err := _this.findEncoding(s, enc)
+	return list, err
+}
+
+// findEncoding 将编码为 enc 的文本 s 转换为 UTF-8 后搜索，返回转换后的文本以及位置已经转换为原始字节位置的结果
+func (_this *Search) findEncoding(s []byte, enc encoding.Encoding) (*transcoded, []*Result, error) {
+	t, err := transcode(s, enc)
+	if err != nil {
+		return nil, nil, err
+	}
+	list := _this.Find(t.text)
+	for _, res := range list {
+		t.remap(res)
+	}
+	return t, list, nil
+}
+
+// ReplaceEncoding 将编码为 enc 的文本 s 中的所有敏感词的每个字节替换为 new，返回同样编码的文本，new 需要是该编码中的单字节字符
+func (_this *Search) ReplaceEncoding(s []byte, enc encoding.Encoding, new byte) ([]byte, error) {
+	list, err := _this.FindEncoding(s, enc)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, len(s))
+	start := 0
+	for _, r := range replaceSpans(list) {
+		copy(data[start:], s[start:r[0]])
+		start = r[0]
+		copy(data[start:], repeatByte(new, r[1]-r[0]+1))
+		start = r[1] + 1
+	}
+	copy(data[start:], s[start:])
+	return data, nil
+}
+
+// ReplaceRuneEncoding 将编码为 enc 的文本 s 中的所有敏感词的每个字符替换为 new，返回同样编码的文本，new 不能用该编码表示时返回错误
+func (_this *Search) ReplaceRuneEncoding(s []byte, enc encoding.Encoding, new rune) ([]byte, error) {
+	rBytes, err := enc.NewEncoder().Bytes([]byte(string(new)))
+	if err != nil {
+		return nil, err
+	}
+	t, list, err := _this.findEncoding(s, enc)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, len(s))
+	start, k := 0, 0 // k 为 t.text 中的下标，用于计算替换区间内的字符数
+	for _, r := range replaceSpans(list) {
+		data = append(data, s[start:r[0]]...)
+		for ; k < len(t.text) && t.starts[k] < r[0]; k++ {
+		}
+		for prev := -1; k < len(t.text) && t.starts[k] <= r[1]; k++ {
+			if t.starts[k] != prev { // 一个原始字符对应多个 UTF-8 字节
+				data = append(data, rBytes...)
+				prev = t.starts[k]
+			}
+		}
+		start = r[1] + 1
+	}
+	return append(data, s[start:]...), nil
+}
+
+// InsertReaderEncoding 将编码为 enc 的 reader 中按分隔符 delim 切分的单词写入到trie树中，返回转换为 UTF-8 后的字节数，
+// 含有无法解码的字节的行会被跳过并记录为 SkipInvalidUTF8，其他与 InsertReader 相同
+func (t *TrieWriter) InsertReaderEncoding(reader io.Reader, enc encoding.Encoding, delim byte) (n int, err error) {
+	return t.InsertReader(transform.NewReader(reader, newDecoder(enc)), delim)
+}
+
+// InsertFileEncoding 将编码为 enc 的文件中的单词（每行一个）写入到trie树中，其他与 InsertFile 相同
+func (t *TrieWriter) InsertFileEncoding(filename string, enc encoding.Encoding) error {
+	return t.insertFile(filename, enc)
+}
+
+// FileEncoding 读取编码为 enc 的文件中的敏感词（每行一个）创建搜索器，其他与 File 相同
+func FileEncoding(filename string, enc encoding.Encoding, skip ...string) (search *Search, err error) {
+	search = NewSearch(SetSortedRunesSkip(skipStr(skip...)))
+	writer := search.TrieWriter()
+	if err = writer.InsertFileEncoding(filename, enc); err != nil {
+		return nil, err
+	}
+	writer.BuildFail()
+	return search, nil
+}
+
+// NetworkEncoding 读取编码为 enc 的网页 pageUrl 中的敏感词（每行一个）创建搜索器，其他与 Network 相同
+func NetworkEncoding(pageUrl string, enc encoding.Encoding, skip ...string) (search *Search, err error) {
+	search = NewSearch(SetSortedRunesSkip(skipStr(skip...)))
+	writer := search.TrieWriter()
+	if err = insertNetwork(writer, pageUrl, enc); err != nil {
+		return nil, err
+	}
+	writer.BuildFail()
+	return search, nil
+}
@@ -0,0 +1,47 @@
+package sensfilter
+
+// Entry 表示一个带有元数据的敏感词，匹配到该敏感词（包括其拼音、缩写等变体）时元数据会出现在结果中
+type Entry struct {
+	Word     string        // 敏感词原文
+	Category string        // 分类，如政治、色情、辱骂
+	Level    int           // 严重等级
+	ID       uint64        // 编号，如数据库中的主键
+	Payload  any           // 用户自定义数据，可以使用 PayloadOf 按类型取出
+	Boundary bool          // 是否只在单词边界处匹配，与 TrieWriter.InsertBoundary 相同
+	Rules    []ContextRule // 上下文例外规则，与 TrieWriter.InsertContext 相同
+	Noise    int           // 相邻两个字符之间最多允许插入的非跳过字符数，0 表示使用搜索器的设置（SetNoise），小于 0 表示不允许
+}
+
+// InsertEntry 向trie树中插入一个带有元数据的单词，单词已经存在时替换其元数据并追加上下文例外规则，返回当前对象。
+func (t *TrieWriter) InsertEntry(e Entry) *TrieWriter {
+	node := t.insert([]byte(e.Word), e.Boundary)
+	if node == nil {
+		return t
+	}
+	meta := e
+	meta.Rules = nil // 规则编译后保存在节点上
+	term := node.terminal()
+	term.meta = &meta
+	for _, rule := range e.Rules {
+		term.rules = append(term.rules, t.compileRule(rule))
+	}
+	if e.Noise != 0 && node.len > 0 { // 模式单词的结尾节点不在trie树中，不支持插入字符
+		runes, _ := t.normalize([]byte(e.Word))
+		t.indexNoise(node, runes, e.Noise)
+	}
+	return t
+}
+
+// InsertEntries 向trie树中插入多个带有元数据的单词，返回当前对象。
+func (t *TrieWriter) InsertEntries(entries []Entry) *TrieWriter {
+	for _, e := range entries {
+		t.InsertEntry(e)
+	}
+	return t
+}
+
+// PayloadOf 返回结果中类型为 T 的用户自定义数据，没有自定义数据或者类型不是 T 时 ok 为false
+func PayloadOf[T any](res *Result) (payload T, ok bool) {
+	payload, ok = res.Payload.(T)
+	return
+}
@@ -38,10 +38,15 @@ type resultWriter struct {
 // DefaultHttpHandler 结构体，用于处理 HTTP 请求
 type DefaultHttpHandler struct{}
 
-// Check 方法用于检查输入文本是否包含敏感词，如果有则返回结果
+// Check 方法用于检查输入文本是否包含敏感词，如果有则返回结果，结果中包含字符下标、UTF-16 下标和行列号等位置信息，
+// 搜索器拒绝含有非法 UTF-8 字节的文本（sensfilter.InvalidReject）时返回 400 和错误信息
 func (h *DefaultHttpHandler) Check(server *Server, context *gin.Context) {
 	text := context.PostForm("text")
-	res := server.Search().Find([]byte(text))
+	res, err := server.Search().WithPosition().TryFind([]byte(text))
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	context.JSON(http.StatusOK, res)
 }
 
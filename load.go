@@ -0,0 +1,116 @@
+package sensfilter
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ErrStatus 表示网页返回的状态码不是 200，此时网页内容不会被当作单词列表
+var ErrStatus = errors.New("unexpected http status")
+
+// LoadError 表示从文件、网页、数据库或 io.Reader 加载单词时的错误，可以通过 errors.Is、errors.As 检查其中的错误
+type LoadError struct {
+	Source string // 数据来源，如文件名、网址、数据表名
+	Line   int    // 出错时正在读取的行号（从 1 开始），与具体的行无关时为 0
+	Err    error  // 原始错误
+}
+
+func (e *LoadError) Error() string {
+	msg := "load words"
+	if e.Source != "" {
+		msg += " from " + e.Source
+	}
+	if e.Line > 0 {
+		msg += fmt.Sprintf(" at line %d", e.Line)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// SkipReason 表示加载时跳过一行的原因
+type SkipReason uint8
+
+const (
+	SkipEmpty       SkipReason = iota + 1 // 去除跳过字符后为空
+	SkipInvalidUTF8                       // 不是合法的 UTF-8 文本，或者按指定的编码加载时含有无法解码的字节
+	SkipTooLong                           // 超过 SetMaxWordLen 设置的长度
+)
+
+func (r SkipReason) String() string {
+	switch r {
+	case SkipEmpty:
+		return "empty"
+	case SkipInvalidUTF8:
+		return "invalid utf-8"
+	case SkipTooLong:
+		return "too long"
+	}
+	return "unknown"
+}
+
+// SkippedLine 表示加载时被跳过的一行
+type SkippedLine struct {
+	Line   int        // 行号（从 1 开始），从数据库加载时为第几条记录
+	Text   string     // 该行的原始内容
+	Reason SkipReason // 跳过的原因
+}
+
+// LoadReport 表示一次加载的结果，空行只计入行数，不算作跳过的行
+type LoadReport struct {
+	Source   string        // 数据来源，如文件名、网址、数据表名
+	Lines    int           // 读取的行数
+	Inserted int           // 插入的行数，已经存在的单词同样计入
+	Skipped  []SkippedLine // 被跳过的行
+}
+
+// Report 返回最近一次通过 InsertReader、InsertScanner、InsertFile、InsertBytes 或者 File、Network、MySQL 等快捷方式加载单词的报告，
+// 还没有加载过时返回nil
+func (t *TrieWriter) Report() *LoadReport {
+	return t.report
+}
+
+// SetMaxWordLen 设置加载时单词的最大字节数，更长的行会被跳过并记录在 Report 中，0 表示不限制，返回当前对象
+func (t *TrieWriter) SetMaxWordLen(n int) *TrieWriter {
+	t.maxWordLen = n
+	return t
+}
+
+// beginLoad 开始一次从 source 的加载，返回新的加载报告
+func (t *TrieWriter) beginLoad(source string) *LoadReport {
+	t.report = &LoadReport{Source: source}
+	return t.report
+}
+
+// loadLine 将读取的一行插入到trie树中，空行只计入行数
+func (t *TrieWriter) loadLine(line []byte) {
+	t.report.Lines++
+	if len(line) == 0 {
+		return
+	}
+	if t.check(line) {
+		t.insert(line, false)
+		t.report.Inserted++
+	}
+}
+
+// check 检查当前行的单词 word 能否插入，不能插入时将其记录到报告中
+func (t *TrieWriter) check(word []byte) bool {
+	var reason SkipReason
+	switch {
+	case !utf8.Valid(word):
+		reason = SkipInvalidUTF8
+	case t.maxWordLen > 0 && len(word) > t.maxWordLen:
+		reason = SkipTooLong
+	default:
+		if runes, _ := t.normalize(word); len(runes) > 0 {
+			return true
+		}
+		reason = SkipEmpty
+	}
+	t.report.Skipped = append(t.report.Skipped, SkippedLine{Line: t.report.Lines, Text: string(word), Reason: reason})
+	return false
+}
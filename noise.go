@@ -0,0 +1,72 @@
+package sensfilter
+
+// noiseWord 表示一个允许在字符之间插入其他字符的单词
+type noiseWord struct {
+	runes []rune // 归一化后的单词
+	node  *trie  // 单词的结尾节点
+	k     int    // 相邻两个字符之间最多允许插入的字符数，不大于 0 时不按这种方式匹配
+}
+
+// noiseSet 保存TrieWriter中允许插入字符的单词
+type noiseSet struct {
+	byLast map[rune][]*noiseWord // 按最后一个字符索引，用于在扫描时找出可能以当前字符结尾的单词
+	byNode map[*trie]*noiseWord  // 按结尾节点索引，用于修改单个单词的设置
+	maxLen int                   // 所有单词在插入字符后能匹配的最长字符数
+}
+
+// setNoise 设置默认允许在单词相邻两个字符之间插入的非跳过字符数，需要在插入单词之前设置
+func (t *TrieWriter) setNoise(k int) *TrieWriter {
+	t.noise = k
+	return t
+}
+
+// indexNoise 设置单词 runes（结尾节点为 node）相邻两个字符之间最多允许插入 k 个字符，k 不大于 0 时取消设置
+func (t *TrieWriter) indexNoise(node *trie, runes []rune, k int) {
+	if len(runes) < 2 {
+		return
+	}
+	if t.noisy == nil {
+		if k <= 0 {
+			return
+		}
+		t.noisy = &noiseSet{byLast: map[rune][]*noiseWord{}, byNode: map[*trie]*noiseWord{}}
+	}
+	set := t.noisy
+	w, ok := set.byNode[node]
+	if !ok {
+		w = &noiseWord{runes: runes, node: node}
+		set.byNode[node] = w
+		last := runes[len(runes)-1]
+		set.byLast[last] = append(set.byLast[last], w)
+	}
+	w.k = k
+	if l := len(runes) + (len(runes)-1)*k; l > set.maxLen {
+		set.maxLen = l
+	}
+}
+
+// matchStart 返回单词以第 end 个字符结尾、相邻字符之间最多插入 k 个字符时最早的起始字符下标，chars 为归一化后的字符，不能匹配时返回-1
+func (w *noiseWord) matchStart(chars []rune, end int) int {
+	if w.k <= 0 || chars[end] != w.runes[len(w.runes)-1] {
+		return -1
+	}
+	// reach 为当前字符可以匹配的所有位置，从后往前逐个字符匹配
+	reach, next := []int{end}, []int(nil)
+	for i := len(w.runes) - 2; i >= 0; i-- {
+		next = next[:0]
+		lowest := end + 1 // next 中已有的最小位置，reach 从大到小排列，因此 next 也从大到小排列且不会重复
+		for _, p := range reach {
+			for q := p - 1; q >= 0 && q >= p-1-w.k; q-- {
+				if q < lowest && chars[q] == w.runes[i] {
+					next = append(next, q)
+					lowest = q
+				}
+			}
+		}
+		if len(next) == 0 {
+			return -1
+		}
+		reach, next = next, reach
+	}
+	return reach[len(reach)-1]
+}
@@ -0,0 +1,126 @@
+package sensfilter
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultLeet 默认的 leetspeak 替换表，将常见的数字、符号替换为对应的小写字母
+var defaultLeet = map[rune]rune{
+	'4': 'a', '@': 'a',
+	'8': 'b',
+	'3': 'e',
+	'6': 'g', '9': 'g',
+	'1': 'i', '!': 'i',
+	'0': 'o',
+	'5': 's', '$': 's',
+	'7': 't', '+': 't',
+}
+
+// DefaultLeet 返回默认的 leetspeak 替换表的副本
+func DefaultLeet() map[rune]rune {
+	table := make(map[rune]rune, len(defaultLeet))
+	for k, v := range defaultLeet {
+		table[k] = v
+	}
+	return table
+}
+
+// normalizer 定义了插入敏感词和搜索文本时对字符所做的归一化处理，两端使用同一个 normalizer 才能保证匹配结果一致
+type normalizer struct {
+	fold   bool          // 是否忽略大小写（Unicode 简单大小写折叠）
+	width  bool          // 是否统一全角、半角字符
+	t2s    bool          // 是否将繁体字转换为简体字
+	skel   bool          // 是否将形近字转换为原型字符
+	leet   map[rune]rune // leetspeak 替换表，nil 表示不开启
+	pinyin bool          // 是否按拼音匹配汉字，开启后 ASCII 字母不区分大小写
+}
+
+// next 解码 s 开头的一个字符并做归一化，返回原始字符、归一化后的字符和消耗的字节数。
+// 开启全半角统一时，半角片假名会和其后的半角浊音、半浊音符号合成为一个全角字符。
+func (_this *normalizer) next(s []byte) (raw, r rune, size int) {
+	raw, size = decodeBytes(s)
+	r = raw
+	if _this.width {
+		r = widthFold(r)
+		if mark, l := decodeBytes(s[size:]); mark == 'ﾞ' || mark == 'ﾟ' {
+			if c := composeKana(r, widthFold(mark)); c != 0 {
+				r = c
+				size += l
+			}
+		}
+	}
+	if l, ok := _this.leet[r]; ok {
+		r = l
+	}
+	if _this.skel {
+		r = skeletonRune(r)
+	}
+	if _this.t2s {
+		r = t2sRune(r)
+	}
+	if _this.fold {
+		r = foldRune(r)
+	}
+	if _this.pinyin && 'A' <= r && r <= 'Z' { // 拼音统一使用小写字母
+		r += 'a' - 'A'
+	}
+	return
+}
+
+// skip 返回原始字符 raw 是否应该被跳过，skip 为用户设置的跳过字符，开启拼音匹配时空白字符也会被跳过，使 "ta ma de" 也能匹配
+func (_this *normalizer) skip(skip *Skip, raw rune) bool {
+	if !skip.ShouldSkip(raw) {
+		return _this.pinyin && unicode.IsSpace(raw)
+	}
+	if _, ok := _this.leet[raw]; ok { // leetspeak 替换表中的字符（如 @、$）优先于跳过字符
+		return false
+	}
+	// 开启形近字转换时，跳过字符中有原型的字母（如默认跳过的希腊字母 ο）不再跳过
+	return !(_this.skel && unicode.IsLetter(raw) && skeletonRune(raw) != raw)
+}
+
+// expand 返回归一化后的字符 r 在搜索时需要展开成的字符串，开启拼音匹配时汉字展开为常用读音，不需要展开时返回空字符串
+func (_this *normalizer) expand(r rune) string {
+	if _this.pinyin {
+		if readings := pinyinOf(r); readings != nil {
+			return readings[0]
+		}
+	}
+	return ""
+}
+
+// foldRune 返回 r 所在大小写折叠轨道中码点最小的字符，同一轨道中的字符（如 k、K、K）折叠后相同
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf { // ASCII 字符轨道中最小的总是大写字母
+		if 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		return r
+	}
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return min
+}
+
+// widthFold 将全角 ASCII 字符、全角空格转换为对应的半角字符，半角片假名等转换为对应的全角字符
+func widthFold(r rune) rune {
+	if f := width.LookupRune(r).Folded(); f != 0 {
+		return f
+	}
+	return r
+}
+
+// composeKana 将假名 r 与随后的浊音、半浊音组合符号 mark 合成为一个字符，无法合成时返回 0
+func composeKana(r, mark rune) rune {
+	if c := []rune(norm.NFC.String(string([]rune{r, mark}))); len(c) == 1 {
+		return c[0]
+	}
+	return 0
+}
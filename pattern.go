@@ -0,0 +1,248 @@
+package sensfilter
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// maxPatternGap 模式中一个间隔最多可以跳过的字符数
+const maxPatternGap = 32
+
+// patternElem 表示模式中的一个元素，set 不为空时匹配 set 中的任意一个字符，为空时表示跳过 min 到 max 个任意字符
+type patternElem struct {
+	set      []rune // 可以匹配的字符（已归一化）
+	min, max int    // 间隔的最少、最多字符数
+}
+
+// pattern 表示一个模式单词，如 "傻?逼"、"傻{0,3}逼"、"[操草艹]你妈"
+type pattern struct {
+	elems  []patternElem
+	maxLen int   // 能匹配的最长字符数
+	node   *trie // 保存单词属性的结尾节点，不在trie树中
+}
+
+// patternSet 保存TrieWriter中的所有模式单词
+type patternSet struct {
+	list   []*pattern          // 按插入顺序排列的模式单词
+	byWord map[string]*pattern // 按模式原文索引，用于去重
+	byLast map[rune][]*pattern // 按最后一个元素可以匹配的字符索引，用于在扫描时找出可能以当前字符结尾的模式
+	maxLen int                 // 所有模式中能匹配的最长字符数
+}
+
+// SetPattern 设置插入单词时是否解析模式语法，开启后 Insert、InsertBytes 等插入方法支持以下写法：
+// "?" 匹配一个任意字符，"{m,n}" 匹配 m 到 n 个任意字符（"{n}" 匹配 n 个，n 最大为 32），
+// "[操草艹]" 匹配方括号中的任意一个字符（支持 "a-z" 这样的范围），"\" 用于转义这些特殊字符。
+// 例如 "傻{0,3}逼" 可以匹配 "傻逼"、"傻了个逼"，任意字符不包含跳过字符。语法有误或者没有使用特殊写法的单词仍然按普通单词插入，
+// 模式中的字符不会展开为拼音和缩写，白名单短语不支持模式语法。需要在插入单词之前设置，返回当前对象。
+func (t *TrieWriter) SetPattern(enable bool) *TrieWriter {
+	t.pattern = enable
+	return t
+}
+
+// insertPattern 将模式单词插入到模式集合中，word 不是合法的模式时返回nil
+func (t *TrieWriter) insertPattern(word []byte, strict bool) *trie {
+	elems, ok := t.parsePattern(word)
+	if !ok {
+		return nil
+	}
+	if t.patterns == nil {
+		t.patterns = &patternSet{byWord: map[string]*pattern{}, byLast: map[rune][]*pattern{}}
+	}
+	set := t.patterns
+	if p, ok := set.byWord[string(word)]; ok { // 模式已经存在
+		p.node.term.strict = p.node.term.strict || strict
+		return p.node
+	}
+
+	p := &pattern{elems: elems}
+	for _, e := range elems {
+		if len(e.set) > 0 {
+			p.maxLen++
+		} else {
+			p.maxLen += e.max
+		}
+	}
+	last := elems[len(elems)-1].set
+	p.node = &trie{
+		end: true,
+		term: &terminal{
+			word:   string(word),
+			strict: strict,
+			seq:    t.nextSeq(),
+		},
+	}
+	set.list = append(set.list, p)
+	set.byWord[p.node.term.word] = p
+	for _, r := range last {
+		set.byLast[r] = append(set.byLast[r], p)
+	}
+	if p.maxLen > set.maxLen {
+		set.maxLen = p.maxLen
+	}
+	t.size++
+	return p.node
+}
+
+// parsePattern 解析模式单词 word，字符与插入单词时一样做归一化处理，跳过字符会被忽略。
+// 只有使用了特殊写法、语法正确并且首尾都不是间隔的单词才是合法的模式。
+func (t *TrieWriter) parsePattern(word []byte) (elems []patternElem, ok bool) {
+	special := false
+	for i := 0; i < len(word); {
+		r, l := utf8.DecodeRune(word[i:])
+		i += l
+		switch r {
+		case '?':
+			elems = append(elems, patternElem{min: 1, max: 1})
+			special = true
+		case '{':
+			j := i
+			for j < len(word) && word[j] != '}' {
+				j++
+			}
+			if j == len(word) {
+				return nil, false
+			}
+			min, max, valid := parseGap(string(word[i:j]))
+			if !valid {
+				return nil, false
+			}
+			elems = append(elems, patternElem{min: min, max: max})
+			i = j + 1
+			special = true
+		case '[':
+			var set []rune
+			for closed := false; !closed; {
+				if i == len(word) {
+					return nil, false
+				}
+				c, l := utf8.DecodeRune(word[i:])
+				i += l
+				switch {
+				case c == ']':
+					closed = true
+				case c == '\\' && i < len(word):
+					c, l = utf8.DecodeRune(word[i:])
+					i += l
+					set = t.appendNormalized(set, c)
+				case i < len(word) && word[i] == '-' && i+1 < len(word) && word[i+1] != ']': // 字符范围
+					to, l := utf8.DecodeRune(word[i+1:])
+					i += 1 + l
+					if to < c || to-c > 0xff {
+						return nil, false
+					}
+					for ; c <= to; c++ {
+						set = t.appendNormalized(set, c)
+					}
+				default:
+					set = t.appendNormalized(set, c)
+				}
+			}
+			if len(set) == 0 {
+				return nil, false
+			}
+			elems = append(elems, patternElem{set: set})
+			special = true
+		default:
+			if r == '\\' && i < len(word) {
+				r, l = utf8.DecodeRune(word[i:])
+				i += l
+				special = true
+			}
+			if set := t.appendNormalized(nil, r); len(set) > 0 {
+				elems = append(elems, patternElem{set: set})
+			}
+		}
+	}
+	if !special || len(elems) == 0 || len(elems[0].set) == 0 || len(elems[len(elems)-1].set) == 0 {
+		return nil, false
+	}
+	return elems, true
+}
+
+// parseGap 解析间隔 "m,n" 或 "n"，返回最少、最多字符数
+func parseGap(s string) (min, max int, ok bool) {
+	lo, hi := s, s
+	for i := 0; i < len(s); i++ {
+		if s[i] == ',' {
+			lo, hi = s[:i], s[i+1:]
+			break
+		}
+	}
+	min, err1 := strconv.Atoi(lo)
+	max, err2 := strconv.Atoi(hi)
+	if err1 != nil || err2 != nil || min < 0 || max < min || max > maxPatternGap {
+		return 0, 0, false
+	}
+	return min, max, true
+}
+
+// appendNormalized 将字符 r 归一化后加入字符集合 set，跳过字符和已经存在的字符不会重复加入
+func (t *TrieWriter) appendNormalized(set []rune, r rune) []rune {
+	raw, v, _ := t.norm.next([]byte(string(r)))
+	if t.norm.skip(t.skip, raw) {
+		return set
+	}
+	for _, c := range set {
+		if c == v {
+			return set
+		}
+	}
+	return append(set, v)
+}
+
+// matchStart 返回模式以第 end 个字符结尾时最早的起始字符下标，chars 为归一化后的字符，不能匹配时返回-1。
+// 与 noiseWord.matchStart 一样从后往前逐个元素计算所有可以到达的位置，时间为 O(元素数 × maxLen)
+func (p *pattern) matchStart(chars []rune, end int) int {
+	lo := end - p.maxLen // 匹配最多 maxLen 个字符，只需要考虑 lo 之后的位置
+	if lo < -1 {
+		lo = -1
+	}
+	// reach[q-lo] 表示已经处理的元素恰好匹配 chars[q+1:end+1]，即之前的元素需要以第 q 个字符结尾
+	reach, next := make([]bool, end-lo+1), make([]bool, end-lo+1)
+	reach[end-lo] = true
+	for k := len(p.elems) - 1; k >= 0; k-- {
+		e, found := p.elems[k], false
+		for i := range next {
+			next[i] = false
+		}
+		if len(e.set) > 0 {
+			for i := 1; i < len(reach); i++ {
+				if reach[i] && containsRune(e.set, chars[lo+i]) {
+					next[i-1], found = true, true
+				}
+			}
+		} else { // 间隔跳过 min 到 max 个字符，count 为 reach[i+min:i+max+1] 中可以到达的位置数
+			for i, count := len(reach)-1, 0; i >= 0; i-- {
+				if j := i + e.min; j < len(reach) && reach[j] {
+					count++
+				}
+				if j := i + e.max + 1; j < len(reach) && reach[j] {
+					count--
+				}
+				if count > 0 {
+					next[i], found = true, true
+				}
+			}
+		}
+		if !found {
+			return -1
+		}
+		reach, next = next, reach
+	}
+	for i, ok := range reach {
+		if ok {
+			return lo + i + 1
+		}
+	}
+	return -1
+}
+
+// containsRune 返回字符集合 set 中是否包含 r
+func containsRune(set []rune, r rune) bool {
+	for _, c := range set {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package sensfilter
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+)
+
+// regexpRule 表示一条正则表达式规则
+type regexpRule struct {
+	re     *regexp.Regexp
+	prefix []byte // 正则表达式必须以其开头的字面前缀，文本中没有该前缀时不需要执行正则表达式
+	node   *trie  // 保存规则属性的结尾节点，不在trie树中
+}
+
+// regexpMatch 表示正则表达式规则在原始文本中的一个匹配，start、end 为匹配的起止字节位置（包含）
+type regexpMatch struct {
+	node       *trie
+	start, end int
+}
+
+// AddRegexp 添加一条正则表达式（RE2 语法）规则，如 "v信\d{5,}"，匹配结果与敏感词一起按位置排序，
+// HasSens、Replace、ReplaceRune 同样生效，结果中的 Word 为正则表达式。
+// 正则表达式直接在原始文本上执行，不会跳过字符也不做归一化，需要忽略大小写时使用 (?i)，匹配首尾的跳过字符不算在结果内。
+// 正则表达式需要完整的文本，只对 Find、FindAll、FindTo 等一次搜索完整文本的方法生效，流式扫描器 Scanner 不会匹配正则表达式规则。
+func (_this *Search) AddRegexp(expr string) error {
+	return _this.AddRegexpEntry(Entry{Word: expr})
+}
+
+// AddRegexps 添加多条正则表达式规则，遇到语法错误时返回错误，之前的规则已经添加
+func (_this *Search) AddRegexps(exprs []string) error {
+	for _, expr := range exprs {
+		if err := _this.AddRegexp(expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddRegexpEntry 添加一条带有元数据的正则表达式规则，e.Word 为正则表达式，元数据、单词边界和上下文例外规则与 TrieWriter.InsertEntry 相同，
+// 与 AddRegexp 一样不会被 Scanner 匹配
+func (_this *Search) AddRegexpEntry(e Entry) error {
+	re, err := regexp.Compile(e.Word)
+	if err != nil {
+		return err
+	}
+	prefix, _ := re.LiteralPrefix()
+	meta := e
+	meta.Rules = nil
+	term := &terminal{word: e.Word, strict: e.Boundary, meta: &meta, seq: _this.trieWriter.nextSeq()}
+	for _, rule := range e.Rules {
+		term.rules = append(term.rules, _this.trieWriter.compileRule(rule))
+	}
+	node := &trie{end: true, term: term}
+	_this.regexps = append(_this.regexps, &regexpRule{re: re, prefix: []byte(prefix), node: node})
+	return nil
+}
+
+// findRegexps 返回所有正则表达式规则在 s 中的非空匹配，按起点从前到后排序，起点相同时更长的在前。
+// 有字面前缀的规则只在 s 中出现该前缀时才执行，执行时 regexp 也会直接跳到前缀出现的位置开始匹配。
+func (_this *Search) findRegexps(s []byte) (list []regexpMatch) {
+	for _, rule := range _this.regexps {
+		if len(rule.prefix) > 0 && !bytes.Contains(s, rule.prefix) {
+			continue
+		}
+		for _, loc := range rule.re.FindAllIndex(s, -1) {
+			if loc[1] > loc[0] {
+				list = append(list, regexpMatch{rule.node, loc[0], loc[1] - 1})
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].start != list[j].start {
+			return list[i].start < list[j].start
+		}
+		return list[i].end > list[j].end
+	})
+	return
+}
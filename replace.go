@@ -0,0 +1,54 @@
+package sensfilter
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// FixedToken 返回将每个敏感词替换为固定文本 token（如 "[censored]"）的替换方式，用于 Search.ReplaceFunc
+func FixedToken(token string) func(*Result) []byte {
+	return func(*Result) []byte {
+		return []byte(token)
+	}
+}
+
+// KeepEnds 返回保留敏感词第一个和最后一个字符、其余每个字符替换为 mask 的替换方式，如 "霸王龙" 替换为 "霸*龙"，
+// 只有两个字符时只保留第一个字符，只有一个字符时全部替换，用于 Search.ReplaceFunc
+func KeepEnds(mask rune) func(*Result) []byte {
+	return func(res *Result) []byte {
+		runes := []rune(res.Matched)
+		n := len(runes)
+		data := make([]byte, 0, len(res.Matched))
+		for i, r := range runes {
+			if i == 0 && n > 1 || i == n-1 && n > 2 {
+				data = utf8.AppendRune(data, r)
+			} else {
+				data = utf8.AppendRune(data, mask)
+			}
+		}
+		return data
+	}
+}
+
+// ByCategory 返回按敏感词分类（Result.Category）选择替换方式的替换方式，categories 中没有的分类使用 def，
+// def 为 nil 时保留原文，用于 Search.ReplaceFunc
+func ByCategory(categories map[string]func(*Result) []byte, def func(*Result) []byte) func(*Result) []byte {
+	return func(res *Result) []byte {
+		if fn, ok := categories[res.Category]; ok {
+			return fn(res)
+		}
+		if def != nil {
+			return def(res)
+		}
+		return []byte(res.Matched)
+	}
+}
+
+// FixedMask 返回将每个敏感词替换为 n 个 mask 的替换方式，替换后的文本不会暴露敏感词的长度，如 FixedMask('*', 3)，
+// 用于 Search.ReplaceFunc
+func FixedMask(mask rune, n int) func(*Result) []byte {
+	data := bytes.Repeat([]byte(string(mask)), n)
+	return func(*Result) []byte {
+		return data
+	}
+}
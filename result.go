@@ -2,13 +2,103 @@ package sensfilter
 
 import (
 	"fmt"
+	"unicode/utf8"
 )
 
 type Result struct {
-	Word    string `json:"word"`    // 匹配到的敏感词
-	Matched string `json:"matched"` // 匹配到的字符串
-	Start   int    `json:"start"`   // 原始字符串中匹配到的起始位置
-	End     int    `json:"end"`     // 原始字符串中匹配到的结束位置
+	Word     string    `json:"word"`               // 匹配到的敏感词
+	Matched  string    `json:"matched"`            // 匹配到的字符串
+	Start    int       `json:"start"`              // 原始字符串中匹配到的起始位置
+	End      int       `json:"end"`                // 原始字符串中匹配到的结束位置
+	Abbr     bool      `json:"abbr,omitempty"`     // 是否是通过敏感词的拼音首字母缩写匹配到的
+	Origin   string    `json:"origin,omitempty"`   // 插入时的敏感词原文（未去除跳过字符）
+	Category string    `json:"category,omitempty"` // 敏感词的分类
+	Level    int       `json:"level,omitempty"`    // 敏感词的严重等级
+	ID       uint64    `json:"id,omitempty"`       // 敏感词的编号
+	Payload  any       `json:"payload,omitempty"`  // 敏感词的用户自定义数据
+	Parts    []*Result `json:"parts,omitempty"`    // 组合规则的结果中组成该结果的各个单词
+	Pos      *Position `json:"pos,omitempty"`      // 匹配的位置信息，开启 SetPosition 时才有
+}
+
+// Position 表示匹配在原始文本中的位置信息，便于 JavaScript、Android 等使用 UTF-16 的前端直接高亮，所有的结束位置都包含在匹配内
+type Position struct {
+	RuneStart  int `json:"runeStart"`  // 第一个字符是文本中的第几个字符（rune，从 0 开始）
+	RuneEnd    int `json:"runeEnd"`    // 最后一个字符是文本中的第几个字符（rune，从 0 开始）
+	UTF16Start int `json:"utf16Start"` // 第一个字符的第一个 UTF-16 码元的下标
+	UTF16End   int `json:"utf16End"`   // 最后一个字符的最后一个 UTF-16 码元的下标
+	Line       int `json:"line"`       // 第一个字符所在的行（从 1 开始，以 '\n' 换行）
+	Column     int `json:"column"`     // 第一个字符在行内是第几个字符（rune，从 1 开始）
+	EndLine    int `json:"endLine"`    // 最后一个字符所在的行
+	EndColumn  int `json:"endColumn"`  // 最后一个字符在行内是第几个字符
+}
+
+// cursor 表示文本中一个字符的位置
+type cursor struct {
+	rune, utf16  int // 字符是文本中的第几个字符、第一个 UTF-16 码元的下标
+	line, column int // 字符所在的行和列，都从 1 开始
+}
+
+// advance 返回 c 之后的字符 r 的位置
+func (c cursor) advance(r rune) cursor {
+	next := cursor{rune: c.rune + 1, utf16: c.utf16 + 1, line: c.line, column: c.column + 1}
+	if r >= 0x10000 { // 辅助平面的字符需要两个 UTF-16 码元
+		next.utf16++
+	}
+	if r == '\n' {
+		next.line, next.column = c.line+1, 1
+	}
+	return next
+}
+
+// lastCursor 返回从位置 c 开始的文本 s 中最后一个字符的位置
+func lastCursor(c cursor, s []byte) cursor {
+	last := c
+	for i := 0; i < len(s); {
+		r, l := utf8.DecodeRune(s[i:])
+		if i += l; i < len(s) {
+			last = last.advance(r)
+		}
+	}
+	return last
+}
+
+// newPosition 根据第一个字符的位置 first 和最后一个字符的位置 last 创建位置信息，last 为辅助平面字符时包含其两个 UTF-16 码元
+func newPosition(first, last cursor, lastRune rune) *Position {
+	pos := &Position{
+		RuneStart:  first.rune,
+		RuneEnd:    last.rune,
+		UTF16Start: first.utf16,
+		UTF16End:   last.utf16,
+		Line:       first.line,
+		Column:     first.column,
+		EndLine:    last.line,
+		EndColumn:  last.column,
+	}
+	if lastRune >= 0x10000 {
+		pos.UTF16End++
+	}
+	return pos
+}
+
+// newResult 根据匹配到的结尾节点 node 创建结果，matched 为匹配到的文本，start、end 为其在原始文本中的起止位置
+func newResult(node *trie, matched []byte, start, end int) *Result {
+	entry := node.entry()
+	res := &Result{
+		Word:    entry.word,
+		Matched: string(matched),
+		Start:   start,
+		End:     end,
+		Abbr:    node.term.abbr,
+		Origin:  entry.word,
+	}
+	if meta := entry.meta; meta != nil {
+		res.Origin = meta.Word
+		res.Category = meta.Category
+		res.Level = meta.Level
+		res.ID = meta.ID
+		res.Payload = meta.Payload
+	}
+	return res
 }
 
 func (_this *Result) String() string {
@@ -0,0 +1,456 @@
+package sensfilter
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// token 表示自动机读入的一个字符在原始文本中的位置
+type token struct {
+	start, end int  // 对应的原始字符的起止位置
+	head, tail bool // 是否是原始字符展开后（如汉字展开为拼音）的第一个、最后一个字符
+}
+
+// compactMin 一次性扫描时丢弃已经确定的字符的最少数量，避免频繁移动数据
+const compactMin = 256
+
+// candidate 表示一个还需要检查单词边界和上下文例外规则的候选匹配
+type candidate struct {
+	match
+	bound uint8 // 需要检查单词边界的边
+}
+
+// scanState 保存 Aho-Corasick 扫描过程中的所有状态。Find 等方法一次写入完整的文本，Scanner 分多次写入文本，写入之间保留状态。
+// 除了 buf 中的下标，所有位置都从整个文本的开头算起：字节位置、tokens 的下标以及 chars 的下标。
+type scanState struct {
+	search  *Search
+	w       ResultWriter // 接收结果的写入器
+	overlap bool         // 返回所有相互重叠的结果
+	combos  bool         // 是否记录组合规则中单词的出现
+
+	buf   []byte // 还需要使用的文本，buf[0] 位于文本的第 base 个字节
+	base  int
+	pos   int    // 下一个需要读入的字节的位置
+	cur   cursor // 下一个需要读入的字符的位置信息，开启 SetPosition 时才记录
+	final bool   // 文本是否已经全部写入
+
+	node     *trie
+	tokens   []token  // 自动机读入的字符，tokens[0] 是第 tokBase 个
+	ats      []cursor // 与 tokens 一一对应的原始字符的位置信息，开启 SetPosition 时才记录
+	tokBase  int
+	chars    []rune // 归一化后的每个原始字符，用于匹配模式单词和允许插入字符的单词，chars[0] 是第 charBase 个
+	heads    []int  // 每个原始字符展开后第一个字符在 tokens 中的下标
+	charBase int
+	pending  []match       // 还不能确定是否输出的候选匹配
+	ready    []match       // selectMatches 使用的临时空间
+	deferred []candidate   // 之后的文本还没有写入，暂时不能检查单词边界和上下文例外规则的候选匹配
+	allows   []match       // 可能覆盖候选匹配的白名单短语
+	last     int           // 上一个输出结果最后一个字符的下标
+	frontier int           // 之后出现的匹配不会早于该下标开始（不考虑 deferred）
+	live     int           // 起点早于该下标的候选匹配都已经确定
+	barrier  int           // 连续跳过字符超过限制的位置之后的第一个原始字符的下标，匹配不能跨过该位置
+	skipped  int           // 当前连续跳过的字符数
+	maxLen   int           // 模式单词和允许插入字符的单词能匹配的最长字符数
+	regs     []regexpMatch // 还没有加入候选匹配的正则表达式匹配
+	hits     []comboHit    // 组合规则中单词的所有出现
+	stop     bool          // 写入器是否已经要求停止
+	err      error         // 使用 InvalidReject 时遇到的非法 UTF-8 字节，此时停止扫描
+}
+
+// newScanState 创建一个新的扫描状态，确定的结果按顺序写入 w
+func (_this *Search) newScanState(w ResultWriter, overlap bool) *scanState {
+	writer := _this.trieWriter
+	st := &scanState{search: _this, w: w, overlap: overlap, node: writer.trie(), last: -1, cur: cursor{line: 1, column: 1}}
+	if writer.patterns != nil {
+		st.maxLen = writer.patterns.maxLen
+	}
+	if writer.noisy != nil && writer.noisy.maxLen > st.maxLen {
+		st.maxLen = writer.noisy.maxLen
+	}
+	return st
+}
+
+// tok 返回第 k 个读入自动机的字符
+func (st *scanState) tok(k int) *token {
+	return &st.tokens[k-st.tokBase]
+}
+
+// ntok 返回已经读入自动机的字符数
+func (st *scanState) ntok() int {
+	return st.tokBase + len(st.tokens)
+}
+
+// text 返回文本中从 start 到 end（包含）的字节
+func (st *scanState) text(start, end int) []byte {
+	return st.buf[start-st.base : end-st.base+1]
+}
+
+// feed 读入 buf 中还没有读入的字符，文本没有结束时，不完整的字符以及可能与之后的字符合成的字符留到下次读入
+func (st *scanState) feed() {
+	writer := st.search.trieWriter
+	skipper := writer.Skip()
+	for !st.stop {
+		rest := st.buf[st.pos-st.base:]
+		if len(rest) == 0 || !st.final && !utf8.FullRune(rest) {
+			break
+		}
+		// 解码以 rest 开头的字节数组，与插入时一样做归一化，位置仍然记录原始字节
+		raw, v, l := writer.norm.next(rest)
+		if !st.final && writer.norm.width && !utf8.FullRune(rest[l:]) && 0xFF61 <= raw && raw <= 0xFF9F { // 半角片假名可能与之后的浊音符号合成
+			break
+		}
+		i, at := st.pos, st.cur
+		st.pos += l
+		if st.search.position { // 跳过的字符同样计入位置
+			for j := 0; j < l; {
+				r, n := utf8.DecodeRune(rest[j:])
+				st.cur = st.cur.advance(r)
+				j += n
+			}
+		}
+		if raw == utf8.RuneError && l == 1 { // 非法的 UTF-8 字节
+			switch st.search.invalid {
+			case InvalidSkip:
+				st.skipped++
+				continue
+			case InvalidReject:
+				st.err = &InvalidUTF8Error{Offset: i}
+				st.stop = true
+				continue
+			}
+			st.node = writer.trie() // 作为分隔符，之前的字符不能再与之后的字符组成敏感词
+			st.barrier = st.charBase + len(st.chars)
+			st.skipped = 0
+			continue
+		}
+		if writer.norm.skip(skipper, raw) { // 跳过一些无意义的字符
+			st.skipped++
+			continue
+		}
+		if max := st.search.maxSkip; max > 0 && st.skipped > max { // 连续跳过的字符太多，之前的字符不能再与之后的字符组成敏感词
+			st.node = writer.trie()
+			st.barrier = st.charBase + len(st.chars)
+		}
+		st.skipped = 0
+		tk := token{i, i + l - 1, true, true}
+		if st.maxLen > 0 {
+			st.chars = append(st.chars, v)
+			st.heads = append(st.heads, st.ntok())
+		}
+
+		expanded := writer.norm.expand(v) // 需要展开的字符（如汉字的拼音）逐个输入自动机
+		if expanded == "" {
+			st.step(v, tk, at)
+		}
+		for j, r := range expanded {
+			tk.head, tk.tail = j == 0, j+utf8.RuneLen(r) == len(expanded)
+			if st.step(r, tk, at); st.stop {
+				break
+			}
+		}
+		if n := st.live - st.tokBase; n >= compactMin && n >= len(st.tokens)/2 { // 已经确定的字符足够多时丢弃
+			st.compact()
+		}
+	}
+	if st.stop {
+		return
+	}
+	if st.final {
+		st.finish()
+	} else if len(st.deferred) > 0 { // 新写入的文本可能已经足够检查之前暂缓的候选匹配
+		st.settle(st.frontier)
+	}
+}
+
+// step 将一个归一化后的字符输入自动机，at 为对应的原始字符的位置信息
+func (st *scanState) step(v rune, tk token, at cursor) {
+	root := st.search.trieWriter.trie()
+	st.tokens = append(st.tokens, tk)
+	if st.search.position {
+		st.ats = append(st.ats, at)
+	}
+
+	// 找不到下一个节点时沿失败指针回退
+	node := st.node
+	for node != root && node.next[v] == nil {
+		node = node.fail
+	}
+	if next := node.next[v]; next != nil {
+		node = next
+	}
+	st.node = node
+
+	// 当前节点及其输出链上的所有结尾节点都是以当前字符结尾的敏感词、白名单短语或组合规则中的单词，
+	// 匹配的首尾必须是完整的原始字符，不能从拼音的中间开始或结束
+	pos := st.ntok() - 1
+	for out := node; out != nil && tk.tail; out = out.out {
+		start := pos - int(out.len) + 1
+		if !out.output() || !st.tok(start).head {
+			continue
+		}
+		if out.allow != 0 {
+			st.allows = append(st.allows, match{out, start, pos})
+		}
+		if out.combo && st.combos {
+			st.hits = append(st.hits, comboHit{st.search.combos.ids[out], out, st.tok(start).start, tk.end})
+		}
+		if out.end {
+			st.add(out, start, pos)
+		}
+	}
+
+	// 之后出现的匹配起点都不会早于当前节点代表的字符串的起点，在此之前开始的候选匹配已经可以确定
+	live := pos - int(node.len) + 1
+	if st.maxLen > 0 {
+		if tk.tail {
+			st.matchChars(pos)
+		}
+		// 之后出现的模式单词和允许插入字符的单词最多从 maxLen 个字符之前开始
+		if c := st.charBase + len(st.chars) - st.maxLen; c < st.charBase {
+			live = 0
+		} else if h := st.heads[c-st.charBase]; h < live {
+			live = h
+		}
+	}
+	if len(st.regs) > 0 {
+		if tk.tail {
+			st.acceptRegexps(tk.end)
+		}
+		// 还没有结束的正则表达式匹配可能从已经读入的字符开始
+		if len(st.regs) > 0 {
+			if k := st.tokenAt(st.regs[0].start); k < live {
+				live = k
+			}
+		}
+	}
+	st.settle(live)
+}
+
+// matchChars 找出以当前字符结尾的模式单词和允许插入字符的单词，pos 为当前字符最后一个展开字符的下标，匹配不能跨过 barrier
+func (st *scanState) matchChars(pos int) {
+	writer := st.search.trieWriter
+	from := st.barrier - st.charBase
+	if from < 0 {
+		from = 0
+	}
+	seg := st.chars[from:]
+	c := len(seg) - 1
+	if writer.patterns != nil {
+		for _, p := range writer.patterns.byLast[seg[c]] {
+			if first := p.matchStart(seg, c); first >= 0 {
+				st.add(p.node, st.heads[from+first], pos)
+			}
+		}
+	}
+	if writer.noisy != nil {
+		for _, w := range writer.noisy.byLast[seg[c]] {
+			if first := w.matchStart(seg, c); first >= 0 {
+				st.add(w.node, st.heads[from+first], pos)
+			}
+		}
+	}
+}
+
+// tokenAt 返回第一个起点不早于字节位置 offset 的字符的下标
+func (st *scanState) tokenAt(offset int) int {
+	return st.tokBase + sort.Search(len(st.tokens), func(k int) bool { return st.tokens[k].start >= offset })
+}
+
+// acceptRegexps 将最后一个字节不晚于 limit 的正则表达式匹配加入候选匹配，匹配首尾的跳过字符不算在内
+func (st *scanState) acceptRegexps(limit int) {
+	for len(st.regs) > 0 && st.regs[0].end <= limit {
+		m := st.regs[0]
+		st.regs = st.regs[1:]
+		start, end := st.tokenAt(m.start), st.tokenAt(m.end+1)-1
+		if start <= end {
+			st.add(m.node, start, end)
+		}
+	}
+}
+
+// add 将从第 start 个到第 end 个读入自动机的字符的匹配作为候选匹配。不输出重叠的结果时，与已经输出的结果重叠的匹配不会再被选中，
+// 并且它开头的文本可能已经被 trim 丢弃，因此直接忽略
+func (st *scanState) add(node *trie, start, end int) {
+	if st.overlapped(start) {
+		return
+	}
+	st.accept(st.candidate(node, start, end))
+}
+
+// overlapped 返回从第 start 个字符开始的匹配是否与已经输出的结果重叠并且不会再被选中
+func (st *scanState) overlapped(start int) bool {
+	return !st.overlap && start <= st.last
+}
+
+// candidate 创建从第 start 个到第 end 个读入自动机的字符的候选匹配，需要检查的单词边界由匹配到的原始文本的首尾字符决定，
+// 因此拼音等变体与原单词一样，汉字开头或结尾的匹配不需要检查
+func (st *scanState) candidate(node *trie, start, end int) candidate {
+	c := candidate{match: match{node, start, end}}
+	if st.search.boundary || node.bounded() {
+		c.bound = matchedBound(st.text(st.tok(start).start, st.tok(end).end))
+	}
+	return c
+}
+
+// accept 检查候选匹配是否满足单词边界和上下文例外规则，满足时加入 pending，需要检查的文本还没有写入时暂缓检查
+func (st *scanState) accept(c candidate) {
+	if !st.lookahead(c) {
+		st.deferred = append(st.deferred, c)
+		return
+	}
+	entry := c.node.entry()
+	start, end := st.tok(c.start).start, st.tok(c.end).end
+	if (st.search.boundary || c.node.bounded()) && !atBoundary(st.buf, start-st.base, end-st.base, c.bound) {
+		return
+	}
+	if len(entry.rules) > 0 && !st.search.checkContext(st.buf, start-st.base, end-st.base, entry.rules) {
+		return
+	}
+	st.pending = append(st.pending, c.match)
+}
+
+// lookahead 返回检查候选匹配所需的之后的文本是否已经写入
+func (st *scanState) lookahead(c candidate) bool {
+	if st.final {
+		return true
+	}
+	entry, need := c.node.entry(), 0
+	if (st.search.boundary || c.node.bounded()) && c.bound&boundEnd != 0 {
+		need = 1
+	}
+	for _, rule := range entry.rules {
+		if rule.After > need {
+			need = rule.After
+		}
+	}
+	for j, n := st.tok(c.end).end+1-st.base, 0; n < need; n++ {
+		if !utf8.FullRune(st.buf[j:]) {
+			return false
+		}
+		_, l := utf8.DecodeRune(st.buf[j:])
+		j += l
+	}
+	return true
+}
+
+// settle 确定起点早于 live 的候选匹配并输出选中的结果
+func (st *scanState) settle(live int) {
+	st.frontier = live
+	n := 0
+	for _, c := range st.deferred {
+		if st.overlapped(c.start) { // 等待期间与新输出的结果重叠
+			continue
+		}
+		if st.lookahead(c) {
+			st.accept(c)
+			continue
+		}
+		st.deferred[n] = c
+		n++
+		if c.start < live {
+			live = c.start
+		}
+	}
+	st.deferred = st.deferred[:n]
+	st.live = live
+
+	st.allows = dropAllowed(st.pending, st.allows, live)
+	if len(st.pending) > 0 {
+		var chosen []match
+		if cap(st.ready) < len(st.pending) {
+			st.ready = make([]match, 0, 2*len(st.pending))
+		}
+		chosen, st.pending, st.last = selectMatches(st.pending, st.ready[:0], live, st.last, st.search.strategy, st.overlap)
+		st.output(chosen)
+	}
+}
+
+// output 将选中的匹配转换为结果写入 w
+func (st *scanState) output(chosen []match) {
+	for _, m := range chosen {
+		start, end := st.tok(m.start).start, st.tok(m.end).end
+		res := newResult(m.node, st.text(start, end), start, end)
+		if st.search.position {
+			res.Pos = st.positionOf(m.start, m.end)
+		}
+		if st.w.Write(res) {
+			st.stop = true
+			return
+		}
+	}
+}
+
+// positionOf 返回从第 first 个到第 last 个读入自动机的字符的匹配的位置信息
+func (st *scanState) positionOf(first, last int) *Position {
+	tk := st.tok(last)
+	text := st.text(tk.start, tk.end)
+	lastRune, _ := utf8.DecodeLastRune(text)
+	return newPosition(st.ats[first-st.tokBase], lastCursor(st.ats[last-st.tokBase], text), lastRune)
+}
+
+// finish 在文本全部写入后确定剩余的候选匹配，并找出满足组合规则的结果，组合规则的结果在最后写入
+func (st *scanState) finish() {
+	st.acceptRegexps(st.pos)
+	if st.settle(st.ntok()); st.stop {
+		return
+	}
+	if st.combos {
+		for _, res := range st.search.findCombos(st.buf, st.hits) {
+			if st.search.position {
+				res.Pos = st.positionOf(st.tokenAt(res.Start), st.tokenAt(res.End+1)-1)
+				for _, p := range res.Parts {
+					p.Pos = st.positionOf(st.tokenAt(p.Start), st.tokenAt(p.End+1)-1)
+				}
+			}
+			if st.w.Write(res) {
+				st.stop = true
+				return
+			}
+		}
+	}
+}
+
+// safe 返回之后的结果都不会早于其开始的字节位置
+func (st *scanState) safe() int {
+	k := st.live
+	if !st.overlap && st.last >= k { // 与已经输出的结果重叠的候选匹配不会再被选中
+		k = st.last + 1
+	}
+	if k < st.ntok() {
+		return st.tok(k).start
+	}
+	return st.pos
+}
+
+// compact 丢弃起点早于 live 的字符以及之后的匹配不会再使用的归一化字符，组合规则的结果最后才计算位置信息，此时保留所有字符
+func (st *scanState) compact() {
+	if st.combos && st.search.position {
+		return
+	}
+	if n := st.live - st.tokBase; n > 0 {
+		st.tokens = append(st.tokens[:0], st.tokens[n:]...)
+		if st.search.position {
+			st.ats = append(st.ats[:0], st.ats[n:]...)
+		}
+		st.tokBase = st.live
+	}
+	if n := len(st.chars) - st.maxLen; n > 0 {
+		st.chars = append(st.chars[:0], st.chars[n:]...)
+		st.heads = append(st.heads[:0], st.heads[n:]...)
+		st.charBase += n
+	}
+}
+
+// trim 丢弃之后的扫描不会再使用的字符和文本，history 为 safe 之前需要保留的字符数，用于检查单词边界和上下文例外规则
+func (st *scanState) trim(history int) {
+	st.compact()
+	cut := st.safe() - st.base
+	for n := 0; n < history && cut > 0; n++ {
+		_, l := utf8.DecodeLastRune(st.buf[:cut])
+		cut -= l
+	}
+	if cut > 0 {
+		st.buf = append(st.buf[:0], st.buf[cut:]...)
+		st.base += cut
+	}
+}
@@ -1,12 +1,123 @@
 package sensfilter
 
 import (
+	"bytes"
+	"fmt"
+	"sort"
 	"unicode/utf8"
 )
 
 // Search 表示一个 tireRoot 树的搜索器
 type Search struct {
 	trieWriter *TrieWriter
+	boundary   bool          // 是否只在单词边界处匹配
+	regexps    []*regexpRule // 与敏感词一起匹配的正则表达式规则
+	combos     *comboSet     // 组合规则，没有组合规则时为nil
+	maxSkip    int           // 敏感词相邻两个字符之间最多允许出现的连续跳过字符数，0 表示不限制
+	strategy   MatchStrategy // 敏感词相互重叠时选择结果的规则
+	position   bool          // 是否在结果中记录位置信息
+	invalid    InvalidPolicy // 待搜索文本中非法 UTF-8 字节的处理方式
+}
+
+// MatchStrategy 表示敏感词相互重叠时选择结果的规则，所有规则都从左往右选出互不重叠的结果，起点靠左的总是优先。
+// 组合规则的结果不参与选择，可能与选出的结果重叠，见 AddCombo
+type MatchStrategy uint8
+
+const (
+	LeftmostLongest MatchStrategy = iota // 最左最长：起点相同时更长的优先，如 "他妈" 和 "他妈的" 在 "他妈的" 中选择 "他妈的"，默认规则
+	LeftmostFirst                        // 最左最先：起点相同时先插入的单词优先，如先插入 "他妈" 时选择 "他妈"
+	Shortest                             // 最左最短：起点相同时更短的优先，如 "他妈" 和 "他妈的" 在 "他妈的" 中选择 "他妈"
+)
+
+// InvalidPolicy 表示待搜索文本中非法 UTF-8 字节的处理方式，合法的 U+FFFD 字符不受影响
+type InvalidPolicy uint8
+
+const (
+	InvalidBreak  InvalidPolicy = iota // 作为分隔符，敏感词不能跨过非法字节匹配，非法字节本身也不会被匹配，默认处理方式
+	InvalidSkip                        // 与跳过字符一样忽略，防止通过在字符之间插入非法字节绕过过滤
+	InvalidReject                      // 拒绝包含非法字节的文本，Try 开头的方法返回 *InvalidUTF8Error，其他方法不返回结果、替换方法原样返回文本
+)
+
+// InvalidUTF8Error 表示待搜索文本中包含非法的 UTF-8 字节
+type InvalidUTF8Error struct {
+	Offset int // 第一个非法字节的位置
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("invalid UTF-8 at byte %d", e.Offset)
+}
+
+// Validate 检查 s 是否是合法的 UTF-8 文本，不是时返回 *InvalidUTF8Error
+func (_this *Search) Validate(s []byte) error {
+	for i := 0; i < len(s); {
+		r, l := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && l == 1 {
+			return &InvalidUTF8Error{Offset: i}
+		}
+		i += l
+	}
+	return nil
+}
+
+// check 在拒绝非法 UTF-8 字节时检查 s
+func (_this *Search) check(s []byte) error {
+	if _this.invalid != InvalidReject {
+		return nil
+	}
+	return _this.Validate(s)
+}
+
+// rejected 返回是否拒绝文本 s，拒绝时不返回任何结果，替换方法原样返回文本
+func (_this *Search) rejected(s []byte) bool {
+	return _this.check(s) != nil
+}
+
+// TryFind 与 Find 相同，使用 InvalidReject 时文本中包含非法的 UTF-8 字节会返回 *InvalidUTF8Error
+func (_this *Search) TryFind(s []byte) ([]*Result, error) {
+	if err := _this.check(s); err != nil {
+		return nil, err
+	}
+	return _this.Find(s), nil
+}
+
+// TryHasSens 与 HasSens 相同，使用 InvalidReject 时文本中包含非法的 UTF-8 字节会返回 *InvalidUTF8Error
+func (_this *Search) TryHasSens(s []byte) (bool, error) {
+	if err := _this.check(s); err != nil {
+		return false, err
+	}
+	return _this.HasSens(s), nil
+}
+
+// TryReplace 与 Replace 相同，使用 InvalidReject 时文本中包含非法的 UTF-8 字节会返回 *InvalidUTF8Error
+func (_this *Search) TryReplace(s []byte, new byte) ([]byte, error) {
+	if err := _this.check(s); err != nil {
+		return nil, err
+	}
+	return _this.Replace(s, new), nil
+}
+
+// TryReplaceRune 与 ReplaceRune 相同，使用 InvalidReject 时文本中包含非法的 UTF-8 字节会返回 *InvalidUTF8Error
+func (_this *Search) TryReplaceRune(s []byte, new rune) ([]byte, error) {
+	if err := _this.check(s); err != nil {
+		return nil, err
+	}
+	return _this.ReplaceRune(s, new), nil
+}
+
+// TryReplaceFunc 与 ReplaceFunc 相同，使用 InvalidReject 时文本中包含非法的 UTF-8 字节会返回 *InvalidUTF8Error
+func (_this *Search) TryReplaceFunc(s []byte, fn func(*Result) []byte) ([]byte, error) {
+	if err := _this.check(s); err != nil {
+		return nil, err
+	}
+	return _this.ReplaceFunc(s, fn), nil
+}
+
+// WithPosition 返回一个共用同一棵trie树和所有规则、但在结果中记录位置信息（Result.Pos）的搜索器，
+// 用于 Strings、File 等快捷方式创建的搜索器
+func (_this *Search) WithPosition() *Search {
+	search := *_this
+	search.position = true
+	return &search
 }
 
 // TrieWriter 返回关联的 TrieWriter
@@ -19,58 +130,150 @@ func decodeBytes(s []byte) (r rune, size int) {
 	return utf8.DecodeRune(s)
 }
 
-// Find 在 tireRoot 树中搜索敏感词并将结果写入 w
+// Find 在 tireRoot 树中搜索敏感词并返回所有结果
 func (_this *Search) Find(s []byte) []*Result {
-	return _this.findByAC(s, false)
+	w := &DefaultResultWriter{}
+	_this.FindTo(s, w)
+	if _this.combos != nil {
+		sortResults(w.list)
+	}
+	return w.List()
+}
+
+// FindAll 在 tireRoot 树中搜索敏感词，与 Find 不同的是会返回所有出现的敏感词，包括相互重叠和嵌套的敏感词，
+// 例如敏感词 "他妈" 和 "他妈的" 在 "他妈的" 中都会被返回。结果按起始位置排序，起始位置相同时更长的在前
+func (_this *Search) FindAll(s []byte) []*Result {
+	w := &DefaultResultWriter{}
+	_this.findByAC(s, w, true)
+	if _this.combos != nil {
+		sortResults(w.list)
+	}
+	return w.List()
+}
+
+// FindTo 在 tireRoot 树中搜索敏感词，按顺序将结果逐个写入 w，w.Write 返回 true 时立即停止搜索。
+// 组合规则的结果需要扫描完整的文本后才能确定，总是在最后写入
+func (_this *Search) FindTo(s []byte, w ResultWriter) {
+	_this.findByAC(s, w, false)
+}
+
+// FindN 在 tireRoot 树中搜索敏感词，最多返回前 n 个结果，找到 n 个结果后立即停止搜索
+func (_this *Search) FindN(s []byte, n int) []*Result {
+	if n <= 0 {
+		return nil
+	}
+	w := &limitResultWriter{limit: n}
+	_this.FindTo(s, w)
+	return w.List()
+}
+
+// Count 返回字节数组 s 中敏感词的数量，与 len(Find(s)) 相同但不保存结果
+func (_this *Search) Count(s []byte) int {
+	w := &countResultWriter{}
+	_this.FindTo(s, w)
+	return w.Len()
 }
 
-// HasSens 检查字节数组 s 是否包含敏感词
+// HasSens 检查字节数组 s 是否包含敏感词，找到第一个敏感词后立即停止搜索
 func (_this *Search) HasSens(s []byte) (has bool) {
-	return len(_this.findByAC(s, true)) > 0
+	w := &simpleResultWriter{}
+	_this.FindTo(s, w)
+	return w.Len() > 0
 }
 
 // Replace 将字节数组 s 中的所有敏感词替换为 new 并返回替换后的字节数组
 func (_this *Search) Replace(s []byte, new byte) []byte {
-	data := make([]byte, len(s))
+	if _this.rejected(s) {
+		return append([]byte(nil), s...)
+	}
+	data := make([]byte, 0, len(s))
 	start := 0
-	for _, r := range _this.Find(s) {
-		copy(data[start:], s[start:r.Start])
-		start = r.Start
-		copy(data[start:], repeatByte(new, r.End-r.Start+1))
-		start = r.End + 1
+	for _, r := range replaceSpans(_this.Find(s)) {
+		data = appendValid(data, s[start:r[0]])
+		data = append(data, repeatByte(new, r[1]-r[0]+1)...)
+		start = r[1] + 1
 	}
-	copy(data[start:], s[start:])
-	return data
+	return appendValid(data, s[start:])
 }
 
 // ReplaceRune 将字节数组 s 中的所有敏感词替换为 new 并返回替换后的字节数组
 func (_this *Search) ReplaceRune(s []byte, new rune) []byte {
-	results := _this.Find(s)
-	newLen := utf8.RuneLen(new)
-	re := make([]int, len(results))
-	addLen := 0
-	for i, r := range results {
-		rl := 0
-		for _ = range r.Matched {
-			rl += newLen
+	if _this.rejected(s) {
+		return append([]byte(nil), s...)
+	}
+	data := make([]byte, 0, len(s))
+	rBytes := []byte(string(new))
+	start := 0
+	for _, r := range replaceSpans(_this.Find(s)) {
+		data = appendValid(data, s[start:r[0]])
+		data = append(data, repeatBytes(rBytes, utf8.RuneCount(s[r[0]:r[1]+1])*len(rBytes))...)
+		start = r[1] + 1
+	}
+	return appendValid(data, s[start:])
+}
+
+// ReplaceFunc 将字节数组 s 中的每个敏感词替换为 fn 返回的内容并返回替换后的字节数组，组合规则的结果逐个替换其中的单词。
+// 与 Replace 一样，相互重叠的结果合并为一个区间后只调用一次 fn，传入的结果覆盖整个区间，其元数据取自其中最长的结果，
+// Parts 为合并的所有结果。内置的替换方式见 FixedToken、KeepEnds、ByCategory、FixedMask。
+func (_this *Search) ReplaceFunc(s []byte, fn func(*Result) []byte) []byte {
+	if _this.rejected(s) {
+		return append([]byte(nil), s...)
+	}
+	data := make([]byte, 0, len(s))
+	start := 0
+	for _, res := range mergeResults(s, _this.Find(s)) {
+		data = appendValid(data, s[start:res.Start])
+		data = append(data, fn(res)...)
+		start = res.End + 1
+	}
+	return appendValid(data, s[start:])
+}
+
+// mergeResults 将结果（组合规则的结果展开为其中的单词）中相互重叠的结果合并，返回按位置排列、互不重叠的结果，
+// 没有与其他结果重叠的结果原样返回，s 为原始文本
+func mergeResults(s []byte, results []*Result) []*Result {
+	var list []*Result
+	for _, res := range results {
+		if len(res.Parts) == 0 {
+			list = append(list, res)
+		} else {
+			list = append(list, res.Parts...)
+		}
+	}
+	sortResults(list)
+	var merged []*Result
+	for i := 0; i < len(list); {
+		j, end, longest := i+1, list[i].End, list[i]
+		for ; j < len(list) && list[j].Start <= end; j++ {
+			if list[j].End > end {
+				end = list[j].End
+			}
+			if list[j].End-list[j].Start > longest.End-longest.Start {
+				longest = list[j]
+			}
+		}
+		if j == i+1 {
+			merged = append(merged, list[i])
+		} else {
+			res := *longest
+			res.Start, res.End = list[i].Start, end
+			res.Matched = string(s[res.Start : res.End+1])
+			res.Pos = nil
+			res.Parts = list[i:j]
+			merged = append(merged, &res)
 		}
-		re[i] = rl
-		addLen += rl - (r.End - r.Start + 1)
+		i = j
 	}
+	return merged
+}
 
-	data := make([]byte, len(s)+addLen)
-	rBytes := []byte(string(new))
-	start, add := 0, 0
-	for i, r := range results {
-		copy(data[start+add:], s[start:r.Start])
-		start = r.Start
-		al := re[i]
-		copy(data[start+add:], repeatBytes(rBytes, al))
-		add += al - (r.End - r.Start + 1)
-		start = r.End + 1
+// appendValid 将敏感词以外的文本 src 追加到 dst，其中的非法 UTF-8 字节（连续的算作一个）替换为 U+FFFD，
+// 替换敏感词时写入的内容由调用方决定，不做处理
+func appendValid(dst, src []byte) []byte {
+	if utf8.Valid(src) {
+		return append(dst, src...)
 	}
-	copy(data[start+add:], s[start:])
-	return data
+	return append(dst, bytes.ToValidUTF8(src, []byte(string(utf8.RuneError)))...)
 }
 
 // repeatBytes 返回一个由 b 字节切片重复组成长度为 l 的字节数组
@@ -91,85 +294,116 @@ func repeatByte(b byte, n int) []byte {
 	return res
 }
 
-// findSub 在字节数组 s 的前 end 个字节中查找子串 sub 的开始位置
-func findSub(s []byte, end int, sub string) (idx int) {
-	idx, j := end, len(sub)-1
-	for ; j >= 0; idx-- {
-		if s[idx] == sub[j] {
-			j--
+// match 表示扫描过程中找到的一个候选匹配，start、end 为首尾两个有效字符（非跳过字符）的下标
+type match struct {
+	node       *trie
+	start, end int
+}
+
+// dropAllowed 将起点小于 limit 且被白名单短语完全覆盖的候选匹配标记为删除（node 置为 nil），
+// 返回之后仍可能覆盖候选匹配的白名单短语
+func dropAllowed(pending, allows []match, limit int) []match {
+	if len(allows) == 0 {
+		return allows
+	}
+	for i, m := range pending {
+		if m.start >= limit || m.node == nil {
+			continue
+		}
+		for _, a := range allows {
+			if a.start <= m.start && a.end >= m.end {
+				pending[i].node = nil
+				break
+			}
 		}
 	}
-	idx++
-	return
+	// 之后的候选匹配起点不小于 limit，结束位置在 limit 之前的短语不会再覆盖任何候选匹配
+	n := 0
+	for _, a := range allows {
+		if a.end >= limit {
+			allows[n] = a
+			n++
+		}
+	}
+	return allows[:n]
 }
 
-// findByAC 是 Aho-Corasick 算法实现的核心函数，用于在 tireRoot 树中搜索敏感词并返回结果
-func (_this *Search) findByAC(s []byte, single bool) (list []*Result) {
-	n := len(s)
-	trieRoot := _this.trieWriter.trie() // 获取 trieRoot 树根节点
-	skipper := _this.trieWriter.Skip()  // 获取跳过字符的规则
-
-	for i := 0; i < n; {
-		v, l := decodeBytes(s[i:])   // 解码以 s[i:] 开头的字节数组，并返回第一个 rune 和其所占字节数
-		node, ok := trieRoot.next[v] // 在 trieRoot 中查找下一个节点
-		if !ok {                     // 如果找不到，则继续向后搜索
-			i += l
+// selectMatches 从起点小于 limit 的候选匹配中按 strategy 规则选出互不重叠的结果，last 为上一个结果的结束下标，
+// overlap 为 true 时按最左最长的顺序选出所有的候选匹配。ready 为临时空间，仍需等待的候选匹配直接保存在 pending 中。
+// 返回选中的匹配、仍需等待的候选匹配以及新的结束下标。
+func selectMatches(pending, ready []match, limit, last int, strategy MatchStrategy, overlap bool) (chosen, rest []match, newLast int) {
+	rest = pending[:0]
+	for _, m := range pending {
+		if m.node == nil { // 已经被删除的候选匹配
 			continue
 		}
-
-		j := i
-		var (
-			word []byte  // 记录匹配到的字符串
-			res  *Result // 记录匹配到的敏感词及相关信息
-			skip int     // 记录跳过的无意义字符数量
-		)
-		for {
-			word = append(word, s[j:j+l]...) // 将找到的字符加入 word 中
-			if node.end {                    // 如果当前节点是一个单词的结尾，则说明找到了一个敏感词
-				sub := string(word[len(word)-int(node.len):])            // 记录匹配的子串
-				end := j + l - 1                                         // 敏感词在 s 中的结束位置
-				start := findSub(s, end, sub)                            // 找到敏感词在 s 中的起始位置
-				res = &Result{sub, string(s[start : end+1]), start, end} // 记录敏感词相关信息
+		if m.start < limit {
+			ready = append(ready, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+	if len(ready) == 0 {
+		return nil, rest, last
+	}
+	if overlap {
+		strategy = LeftmostLongest
+	}
+	sort.Slice(ready, func(i, j int) bool { // 起点靠左的优先，起点相同时按 strategy 规则选择
+		a, b := ready[i], ready[j]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		switch strategy {
+		case LeftmostFirst:
+			if sa, sb := a.node.entry().seq, b.node.entry().seq; sa != sb {
+				return sa < sb
 			}
-
-			j += l
-			// 跳过一些无意义的字符
-			for {
-				v, l = decodeBytes(s[j:])
-				if j < n && skipper.ShouldSkip(v) { // 如果当前字符是无意义字符，则跳过
-					j += l
-					skip += l
-				} else {
-					break
-				}
+		case Shortest:
+			return a.end < b.end
+		}
+		return a.end > b.end
+	})
+	if overlap { // 同一个单词可能通过不同的方式（如允许插入字符）在同一位置匹配到，只保留一个
+		for i, m := range ready {
+			dup := false
+			for j := len(chosen) - 1; j >= 0 && chosen[j].start == m.start && chosen[j].end == m.end; j-- {
+				dup = dup || chosen[j].node == m.node
 			}
-
-			// 如果找不到尝试fail指针
-			if next := node.next[v]; next != nil {
-				node = next
-			} else {
-				if res == nil && node.fail.end {
-					sub := string(word[len(word)-int(node.fail.len):])       // 记录匹配的子串
-					end := j - 1                                             // 敏感词在 s 中的结束位置
-					start := findSub(s, end, sub)                            // 找到敏感词在 s 中的起始位置
-					res = &Result{sub, string(s[start : end+1]), start, end} // 记录敏感词相关信息
-				}
-				if res != nil {
-					list = append(list, res)
-					word = word[:0]
-					if single {
-						return
-					}
-				}
-				res = nil
-				if node = node.fail.next[v]; node == nil {
-					i = j
-					break
-				} else {
-					i = j + l - int(node.len) - skip
-				}
+			if !dup {
+				chosen = append(chosen, ready[i])
 			}
 		}
+		return chosen, rest, last
+	}
+	for _, m := range ready {
+		if m.start > last {
+			chosen = append(chosen, m)
+			last = m.end
+		}
+	}
+	// 与已选中结果重叠的候选匹配不会再被选中
+	n := 0
+	for _, m := range rest {
+		if m.start > last {
+			rest[n] = m
+			n++
+		}
+	}
+	return chosen, rest[:n], last
+}
+
+// findByAC 是 Aho-Corasick 算法实现的核心函数，用于在 tireRoot 树中搜索敏感词并将结果写入 w，
+// overlap 为 true 时写入所有相互重叠的结果
+func (_this *Search) findByAC(s []byte, w ResultWriter, overlap bool) {
+	if _this.rejected(s) { // 结果会逐个写入 w，需要在扫描之前检查
+		return
+	}
+	st := _this.newScanState(w, overlap)
+	st.buf, st.final = s, true
+	st.combos = _this.combos != nil
+	if len(_this.regexps) > 0 {
+		st.regs = _this.findRegexps(s)
 	}
-	return
+	st.feed()
 }
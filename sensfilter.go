@@ -1,11 +1,15 @@
 package sensfilter
 
 import (
+	"fmt"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"io"
 	"net/http"
 	"sort"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
 )
 
 type simpleResultWriter struct {
@@ -21,6 +25,31 @@ func (_this *simpleResultWriter) Len() int {
 	return _this.count
 }
 
+// countResultWriter 只统计结果的数量
+type countResultWriter struct {
+	count int
+}
+
+func (_this *countResultWriter) Write(_ *Result) (stop bool) {
+	_this.count++
+	return false
+}
+
+func (_this *countResultWriter) Len() int {
+	return _this.count
+}
+
+// limitResultWriter 保存前 limit 个结果，达到数量后停止写入
+type limitResultWriter struct {
+	DefaultResultWriter
+	limit int
+}
+
+func (_this *limitResultWriter) Write(res *Result) (stop bool) {
+	_this.DefaultResultWriter.Write(res)
+	return _this.Len() >= _this.limit
+}
+
 func skipStr(skip ...string) []rune {
 	if len(skip) > 0 {
 		runes := []rune(skip[0])
@@ -41,55 +70,144 @@ func Strings(words []string, skip ...string) *Search {
 
 func Network(pageUrl string, skip ...string) (search *Search, err error) {
 	search = NewSearch(SetSortedRunesSkip(skipStr(skip...)))
+	writer := search.TrieWriter()
+	if err = insertNetwork(writer, pageUrl, encoding.Nop); err != nil {
+		return nil, err
+	}
+	writer.BuildFail()
+	return search, nil
+}
+
+// insertNetwork 读取编码为 enc 的网页 pageUrl 的内容，按行写入 writer
+func insertNetwork(writer *TrieWriter, pageUrl string, enc encoding.Encoding) error {
+	data, err := fetchNetwork(pageUrl)
+	if err != nil {
+		return err
+	}
+	if data, _, err = transform.Bytes(newDecoder(enc), data); err != nil {
+		return &LoadError{Source: pageUrl, Err: err}
+	}
+	writer.beginLoad(pageUrl)
+	writer.loadBytes(data, '\n')
+	return nil
+}
+
+// fetchNetwork 读取网页 pageUrl 的内容，请求失败或者状态码不是 200 时返回 *LoadError
+func fetchNetwork(pageUrl string) ([]byte, error) {
 	resp, err := http.Get(pageUrl)
 	if err != nil {
-		return nil, err
+		return nil, &LoadError{Source: pageUrl, Err: err}
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
-	writer := search.TrieWriter()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &LoadError{Source: pageUrl, Err: fmt.Errorf("%w: %s", ErrStatus, resp.Status)}
+	}
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, &LoadError{Source: pageUrl, Err: err}
 	}
-	writer.InsertBytes(data, '\n')
-	writer.BuildFail()
-	return search, nil
+	return data, nil
 }
 
 func File(filename string, skip ...string) (search *Search, err error) {
 	search = NewSearch(SetSortedRunesSkip(skipStr(skip...)))
 	writer := search.TrieWriter()
-	writer.InsertFile(filename)
+	if err = writer.InsertFile(filename); err != nil {
+		return nil, err
+	}
 	writer.BuildFail()
-	return &Search{writer}, nil
+	return search, nil
 }
 
 func MySQL(conf *DatabaseConf, skip ...string) (search *Search, err error) {
 	search = NewSearch(SetSortedRunesSkip(skipStr(skip...)))
+	writer := search.TrieWriter()
+	if err = insertMySQL(writer, conf); err != nil {
+		return nil, err
+	}
+	writer.BuildFail()
+	return search, nil
+}
+
+// insertMySQL 读取数据库表 conf.TableName 中的 word 字段写入 writer，id 字段作为敏感词的编号
+func insertMySQL(writer *TrieWriter, conf *DatabaseConf) error {
+	words, err := queryMySQL(conf)
+	if err != nil {
+		return err
+	}
+	report := writer.beginLoad(conf.TableName)
+	for _, w := range words {
+		report.Lines++
+		if writer.check([]byte(w.Word)) {
+			writer.InsertEntry(Entry{Word: w.Word, ID: uint64(w.ID)})
+			report.Inserted++
+		}
+	}
+	return nil
+}
+
+// queryMySQL 读取数据库表 conf.TableName 中的 id 和 word 字段，连接或查询失败时返回 *LoadError
+func queryMySQL(conf *DatabaseConf) (words []SensitiveWord, err error) {
 	// 连接数据库
 	db, err := gorm.Open(mysql.Open(conf.DSN), &gorm.Config{})
 	if err != nil {
-		return nil, err
+		return nil, &LoadError{Source: conf.TableName, Err: err}
 	}
 	// 查询指定的字段
-	var words []struct {
-		Word string
+	if err = db.Table(conf.TableName).Select("id", "word").Find(&words).Error; err != nil {
+		return nil, &LoadError{Source: conf.TableName, Err: err}
 	}
+	return words, nil
+}
 
-	writer := search.TrieWriter()
-	db.Table(conf.TableName).Select("word").Find(&words)
-	for _, w := range words {
-		writer.Insert(w.Word)
+// AllowStrings 将字符串数组中的短语加入白名单，被白名单短语完全覆盖的敏感词不会再被匹配，返回当前对象
+func (_this *Search) AllowStrings(words []string) *Search {
+	_this.trieWriter.Allow().InsertWords(words).BuildFail()
+	return _this
+}
+
+// AllowFile 将文件中的短语（每行一个）加入白名单
+func (_this *Search) AllowFile(filename string) error {
+	writer := _this.trieWriter.Allow()
+	if err := writer.InsertFile(filename); err != nil {
+		return err
+	}
+	writer.BuildFail()
+	return nil
+}
+
+// AllowNetwork 将网页 pageUrl 中的短语（每行一个）加入白名单
+func (_this *Search) AllowNetwork(pageUrl string) error {
+	writer := _this.trieWriter.Allow()
+	if err := insertNetwork(writer, pageUrl, encoding.Nop); err != nil {
+		return err
+	}
+	writer.BuildFail()
+	return nil
+}
+
+// AllowMySQL 将数据库表 conf.TableName 中 word 字段的短语加入白名单
+func (_this *Search) AllowMySQL(conf *DatabaseConf) error {
+	writer := _this.trieWriter.Allow()
+	if err := insertMySQL(writer, conf); err != nil {
+		return err
 	}
 	writer.BuildFail()
-	return &Search{writer}, nil
+	return nil
 }
 
 type options struct {
-	writer *TrieWriter
-	skip   *Skip
+	writer   *TrieWriter
+	skip     *Skip
+	norm     normalizer
+	boundary bool
+	noise    int
+	maxSkip  int
+	strategy MatchStrategy
+	position bool
+	invalid  InvalidPolicy
 }
 
 type Option func(options *options)
@@ -123,6 +241,116 @@ func SetSkip(s string) Option {
 	}
 }
 
+// SetCaseFold 设置是否忽略大小写，开启后敏感词和待搜索文本都按 Unicode 简单大小写折叠规则比较，
+// 例如敏感词 "TMD" 可以匹配 "tmd"、"TmD"，结果中的位置仍然对应原始字节
+func SetCaseFold(fold bool) Option {
+	return func(options *options) {
+		options.norm.fold = fold
+	}
+}
+
+// SetWidthFold 设置是否统一全角、半角字符，开启后全角字母数字、全角空格会按半角处理，半角片假名会按全角处理，
+// 例如敏感词 "TMD" 可以匹配 "ＴＭＤ"，结果中的位置仍然对应原始字节
+func SetWidthFold(width bool) Option {
+	return func(options *options) {
+		options.norm.width = width
+	}
+}
+
+// SetTraditionalFold 设置是否统一繁简体，开启后敏感词和待搜索文本中的繁体字都按简体字比较，
+// 例如敏感词 "他妈的" 可以匹配 "他媽的"，结果中的 Matched 仍然是原始的繁体文本
+func SetTraditionalFold(t2s bool) Option {
+	return func(options *options) {
+		options.norm.t2s = t2s
+	}
+}
+
+// SetPinyin 设置是否按拼音匹配汉字，开启后含汉字的敏感词会同时按拼音（多音字展开所有读音）写入，
+// 待搜索文本中的汉字按常用读音比较，因此汉字、拼音以及两者混合的写法（如 "他ma的"、"ta ma de"）都能匹配，
+// 结果中的 Word 仍然是原敏感词。开启后 ASCII 字母不区分大小写，空白字符会被跳过，读音相同的同音字也会被匹配。
+func SetPinyin(pinyin bool) Option {
+	return func(options *options) {
+		options.norm.pinyin = pinyin
+	}
+}
+
+// SetConfusableFold 设置是否统一形近字，开启后敏感词和待搜索文本中的字符都按 Unicode UTS #39 的形近字原型比较，
+// 例如敏感词 "fuck" 可以匹配 "fuсk"（西里尔字母 с）、"𝐟𝐮𝐜𝐤"、"ⓕⓤⓒⓚ"，结果中的位置仍然对应原始字节。
+// 开启后跳过字符中有原型的字母（如希腊字母 ο）不再被跳过。
+func SetConfusableFold(skel bool) Option {
+	return func(options *options) {
+		options.norm.skel = skel
+	}
+}
+
+// SetLeet 开启 leetspeak 匹配，敏感词和待搜索文本中的字符都按替换表转换后再比较，例如敏感词 "shit" 可以匹配 "sh1t"、"$hit"。
+// table 会合并到默认替换表 DefaultLeet 中，相同的字符以 table 为准，替换为 0 表示从默认替换表中删除该字符。
+// 替换表中的字符即使在跳过字符列表中（如 @、$）也不会被跳过。替换后的字母为小写，需要忽略大小写时配合 SetCaseFold 使用。
+func SetLeet(table map[rune]rune) Option {
+	return func(options *options) {
+		leet := DefaultLeet()
+		for k, v := range table {
+			if v == 0 {
+				delete(leet, k)
+			} else {
+				leet[k] = v
+			}
+		}
+		options.norm.leet = leet
+	}
+}
+
+// SetWordBoundary 设置是否只在单词边界处匹配，开启后以字母或数字开头（结尾）的敏感词前（后）不能紧挨着字母或数字，
+// 例如敏感词 "ass" 不会匹配 "class"、"passport"，汉字等不使用空格分词的文字仍然按子串匹配
+func SetWordBoundary(boundary bool) Option {
+	return func(options *options) {
+		options.boundary = boundary
+	}
+}
+
+// SetNoise 设置允许在敏感词相邻两个字符之间插入最多 k 个非跳过字符，例如 k 为 2 时敏感词 "空子" 可以匹配 "空ss子"，
+// 单个单词可以通过 Entry.Noise 单独设置。只对之后插入的单词生效，插入字符后的匹配不会展开为拼音和缩写，
+// 通常配合 SetMaxSkip 使用，以免分散在一大段文本中的字符被当作敏感词
+func SetNoise(k int) Option {
+	return func(options *options) {
+		options.noise = k
+	}
+}
+
+// SetMaxSkip 设置敏感词相邻两个字符之间最多允许出现的连续跳过字符数，超过时不再认为是同一个敏感词，
+// 例如 n 为 3 时敏感词 "空子" 不会匹配 "空.........子"，0 表示不限制
+func SetMaxSkip(n int) Option {
+	return func(options *options) {
+		options.maxSkip = n
+	}
+}
+
+// SetStrategy 设置敏感词相互重叠时选择结果的规则，默认为 LeftmostLongest，Find、HasSens、Replace、ReplaceRune 都使用该规则
+func SetStrategy(strategy MatchStrategy) Option {
+	return func(options *options) {
+		options.strategy = strategy
+	}
+}
+
+// SetPosition 设置是否在结果中记录位置信息（Result.Pos），包括字符（rune）下标、UTF-16 码元下标以及行号和列号，
+// 在搜索的同时计算，开启后会有少量额外开销
+func SetPosition(position bool) Option {
+	return func(options *options) {
+		options.position = position
+	}
+}
+
+// SetInvalidUTF8 设置待搜索文本中非法 UTF-8 字节的处理方式，默认为 InvalidBreak。
+// 使用 InvalidReject 时，TryFind、TryHasSens、TryReplace、TryReplaceRune、TryReplaceFunc 对含有非法字节的文本返回错误，
+// Find、FindAll、FindTo、FindN、Count、HasSens 等不返回错误的方法不返回任何结果，Replace、ReplaceRune、ReplaceFunc 原样返回文本，
+// Scanner 遇到非法字节后不再返回结果，通过 Scanner.Err 获取错误。
+// 使用其他方式时，Replace、ReplaceRune、ReplaceFunc 返回的都是合法的 UTF-8 文本，敏感词以外的非法字节会被替换为 U+FFFD
+func SetInvalidUTF8(policy InvalidPolicy) Option {
+	return func(options *options) {
+		options.invalid = policy
+	}
+}
+
 func NewSearch(opts ...Option) *Search {
 	opt := &options{
 		skip:   &Skip{list: []rune(sortedSkipList)},
@@ -132,5 +360,7 @@ func NewSearch(opts ...Option) *Search {
 		o(opt)
 	}
 	opt.writer.setSkip(opt.skip)
-	return &Search{opt.writer}
+	opt.writer.setNormalizer(opt.norm)
+	opt.writer.setNoise(opt.noise)
+	return &Search{trieWriter: opt.writer, boundary: opt.boundary, maxSkip: opt.maxSkip, strategy: opt.strategy, position: opt.position, invalid: opt.invalid}
 }
@@ -3,13 +3,19 @@ package sensfilter
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"sort"
 	"strings"
 	"testing"
 	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
 )
 
 type myWriter struct {
@@ -47,7 +53,7 @@ next:
 		for {
 			word = append(word, v)
 			if node.end {
-				res = &Result{string(word), string(s[i : j+l]), i, j + l}
+				res = &Result{Word: string(word), Matched: string(s[i : j+l]), Start: i, End: j + l}
 			}
 
 			j += l
@@ -102,7 +108,7 @@ next:
 		for {
 			word = append(word, v)
 			if node.end {
-				res := &Result{string(word), s[i : j+l], i, j + l}
+				res := &Result{Word: string(word), Matched: s[i : j+l], Start: i, End: j + l}
 				stop := w.Write(res)
 				if stop {
 					return
@@ -138,7 +144,7 @@ func BenchmarkA(b *testing.B) {
 	str := []byte("我空ss子sss我是霸**王*龙,我是我我是个(S)(B)真的")
 	var res []*Result
 	for i := 0; i < b.N; i++ {
-		res = obj.findByAC(str, false)
+		res = obj.Find(str)
 	}
 	fmt.Println(res)
 }
@@ -541,3 +547,1163 @@ func TestTrieWriter_InsertScanner(t *testing.T) {
 		}
 	}
 }
+
+func TestSearch_FindCaseFold(t *testing.T) {
+	obj := NewSearch(SetCaseFold(true))
+	obj.TrieWriter().InsertWords([]string{"TMD", "сука", "Straße"}).BuildFail()
+
+	str := "tmd,TmD;СУКА Сука STRASSE straße"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+		start   int
+		end     int
+	}
+
+	wants := []wantPair{
+		{"TMD", "tmd", 0, 2},
+		{"TMD", "TmD", 4, 6},
+		{"сука", "СУКА", 8, 15},
+		{"сука", "Сука", 17, 24},
+		{"Straße", "straße", 34, 40},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched || v.Start != want.start || v.End != want.end {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+
+	if Strings([]string{"TMD"}).HasSens([]byte("tmd")) {
+		t.Fatalf("Case folding should be disabled by default.")
+	}
+}
+
+func TestSearch_FindOverlapping(t *testing.T) {
+	obj := Strings([]string{"ab", "bc", "他妈", "他妈的"})
+	res := obj.Find([]byte("abc他妈的"))
+	wants := []string{"ab", "他妈的"}
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+	for i, v := range res {
+		if v.Word != wants[i] {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", wants[i], v)
+		}
+	}
+}
+
+func TestSearch_FindWidthFold(t *testing.T) {
+	obj := NewSearch(SetWidthFold(true), SetSkip(""))
+	obj.TrieWriter().InsertWords([]string{"TMD", "fuck", "a b", "バカ", "ﾊﾟﾁﾝｺ"}).BuildFail()
+
+	str := "ＴＭＤ，ｆｕｃｋ；a　b；ﾊﾞｶ；パチンコ"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+		start   int
+		end     int
+	}
+
+	wants := []wantPair{
+		{"TMD", "ＴＭＤ", 0, 8},
+		{"fuck", "ｆｕｃｋ", 12, 23},
+		{"a b", "a　b", 27, 31},
+		{"バカ", "ﾊﾞｶ", 35, 43},
+		{"ﾊﾟﾁﾝｺ", "パチンコ", 47, 58},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched || v.Start != want.start || v.End != want.end {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+
+	both := NewSearch(SetWidthFold(true), SetCaseFold(true))
+	both.TrieWriter().Insert("tmd").BuildFail()
+	if !both.HasSens([]byte("ｔＭｄ")) {
+		t.Fatalf("Expected to find the word tmd with mixed width and case, but did not find it.")
+	}
+}
+
+func TestSearch_FindTraditionalFold(t *testing.T) {
+	obj := NewSearch(SetTraditionalFold(true))
+	obj.TrieWriter().InsertWords([]string{"他妈的", "發票"}).BuildFail()
+
+	str := "他媽的，他妈的，代开发票"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+	}
+
+	wants := []wantPair{
+		{"他妈的", "他媽的"},
+		{"他妈的", "他妈的"},
+		{"發票", "发票"},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+
+	if Strings([]string{"他妈的"}).HasSens([]byte("他媽的")) {
+		t.Fatalf("Traditional folding should be disabled by default.")
+	}
+}
+
+func TestSearch_FindPinyin(t *testing.T) {
+	obj := NewSearch(SetPinyin(true))
+	obj.TrieWriter().InsertWords([]string{"他妈的", "傻逼"}).BuildFail()
+
+	str := "他妈的|ta ma de|他ma的|TaMade|sha逼|他们的"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+	}
+
+	wants := []wantPair{
+		{"他妈的", "他妈的"},
+		{"他妈的", "ta ma de"},
+		{"他妈的", "他ma的"},
+		{"他妈的", "TaMade"},
+		{"傻逼", "sha逼"},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+
+	if obj.TrieWriter().Size() != 2 || len(obj.TrieWriter().Array()) != 2 {
+		t.Fatalf("Pinyin variants should not be counted as sensitive words.")
+	}
+
+	// 汉字的拼音不能只匹配一部分
+	part := NewSearch(SetPinyin(true))
+	part.TrieWriter().InsertWords([]string{"ama"}).BuildFail()
+	if part.HasSens([]byte("他妈")) {
+		t.Fatalf("Expected no sensitive words inside the pinyin of a character, but found some.")
+	}
+}
+
+func TestTrieWriter_SetAbbreviation(t *testing.T) {
+	obj := NewSearch(SetCaseFold(true))
+	obj.TrieWriter().SetAbbreviation(3).InsertWords([]string{"他妈的", "你妈死了", "傻逼", "操你妈"}).BuildFail()
+
+	str := "tmd,NMSL,sb,cnm,他妈的,utmdx,nmsl2,他cnm的"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+		abbr    bool
+	}
+
+	wants := []wantPair{
+		{"他妈的", "tmd", true},
+		{"你妈死了", "NMSL", true},
+		{"操你妈", "cnm", true},
+		{"他妈的", "他妈的", false},
+		{"操你妈", "cnm", true},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched || v.Abbr != want.abbr {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+
+	if obj.TrieWriter().Size() != 4 {
+		t.Fatalf("Abbreviations should not be counted as sensitive words.")
+	}
+
+	if obj.HasSens([]byte("utmdx")) || obj.HasSens([]byte("Scnmx")) {
+		t.Fatalf("Abbreviations should not match inside a longer English word.")
+	}
+}
+
+func TestSearch_FindConfusableFold(t *testing.T) {
+	obj := NewSearch(SetConfusableFold(true))
+	obj.TrieWriter().InsertWords([]string{"fuck", "bad"}).BuildFail()
+
+	str := "fuсk,fυck,𝐟𝐮𝐜𝐤,ⓕⓤⓒⓚ,bаd,fuck"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+	}
+
+	wants := []wantPair{
+		{"fuck", "fuсk"},
+		{"fuck", "fυck"},
+		{"fuck", "𝐟𝐮𝐜𝐤"},
+		{"fuck", "ⓕⓤⓒⓚ"},
+		{"bad", "bаd"},
+		{"fuck", "fuck"},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+
+	if Strings([]string{"fuck"}).HasSens([]byte("fuсk")) {
+		t.Fatalf("Confusable folding should be disabled by default.")
+	}
+}
+
+func TestSearch_FindLeet(t *testing.T) {
+	obj := NewSearch(SetLeet(map[rune]rune{'#': 'h', '!': 0}))
+	obj.TrieWriter().InsertWords([]string{"shit", "ass", "TMD"}).BuildFail()
+
+	str := "sh1t,$hit,5#it,@ss,T!MD"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+	}
+
+	wants := []wantPair{
+		{"shit", "sh1t"},
+		{"shit", "$hit"},
+		{"shit", "5#it"},
+		{"ass", "@ss"},
+		{"TMD", "T!MD"},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+
+	// 没有开启 leetspeak 时 @、$ 仍然是跳过字符
+	if res := Strings([]string{"ss"}).Find([]byte("@s$s")); len(res) != 1 || res[0].Matched != "s$s" {
+		t.Fatalf("Skip characters should be skipped without leetspeak, result: %v", res)
+	}
+}
+
+func TestSearch_FindWordBoundary(t *testing.T) {
+	obj := NewSearch(SetWordBoundary(true))
+	obj.TrieWriter().InsertWords([]string{"ass", "сука", "110", "鸭子"}).BuildFail()
+
+	str := "class,assassin,passport,ass!,СУКА сука,1100,110,烤鸭子好吃"
+	res := obj.Find([]byte(str))
+
+	wants := []string{"ass", "сука", "110", "鸭子"}
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+	for i, v := range res {
+		if v.Word != wants[i] {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", wants[i], v)
+		}
+	}
+
+	// 单个单词也可以要求只在单词边界处匹配
+	word := Strings([]string{"bc"})
+	word.TrieWriter().InsertBoundary("ass").BuildFail()
+	res = word.Find([]byte("class abc ass"))
+	if len(res) != 2 || res[0].Word != "bc" || res[1].Start != 10 {
+		t.Fatalf("Unexpected result with boundary word: %v", res)
+	}
+
+	// 拼音匹配时汉字仍然按子串匹配，拼音写法和字母单词需要位于单词边界
+	pinyin := NewSearch(SetPinyin(true), SetWordBoundary(true))
+	pinyin.TrieWriter().InsertWords([]string{"他妈的", "ass"}).BuildFail()
+	res = pinyin.Find([]byte("abc他妈的,他妈的x,xtamade,ta ma de,class,ass"))
+	wants = []string{"他妈的", "他妈的", "他妈的", "ass"}
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+	for i, v := range res {
+		if v.Word != wants[i] {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", wants[i], v)
+		}
+	}
+	if res[2].Matched != "ta ma de" {
+		t.Fatalf("Unexpected result with pinyin boundary: %v", res)
+	}
+}
+
+func TestSearch_AllowStrings(t *testing.T) {
+	obj := Strings([]string{"打飞机", "cunt", "飞机"}).AllowStrings([]string{"打飞机场", "Scunthorpe"})
+
+	str := "去打*飞机场,想打飞机,Scunthorpe,cunt"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+	}
+
+	wants := []wantPair{
+		{"打飞机", "打飞机"},
+		{"cunt", "cunt"},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+
+	if obj.HasSens([]byte("打飞机场")) {
+		t.Fatalf("Expected no sensitive words in allowed phrase, but found some.")
+	}
+	if output := obj.Replace([]byte("Scunthorpe cunt"), '*'); string(output) != "Scunthorpe ****" {
+		t.Fatalf("Unexpected output. Got: %s.", output)
+	}
+	if obj.TrieWriter().Size() != 3 || obj.TrieWriter().Allow().Size() != 2 {
+		t.Fatalf("Allowed phrases should be counted separately.")
+	}
+}
+
+func TestTrieWriter_InsertContext(t *testing.T) {
+	obj := Strings([]string{"出售"})
+	obj.TrieWriter().
+		InsertContext("吸毒", ContextRule{Text: "禁止", Before: 3}, ContextRule{Text: "拒绝", Before: 3}).
+		InsertContext("枪", ContextRule{Text: "出售", Before: 4, Require: true}).
+		BuildFail()
+
+	str := "禁止吸毒，拒绝*吸毒，他在吸毒；水枪玩具，出售枪支"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+	}
+
+	wants := []wantPair{
+		{"吸毒", "吸毒"},
+		{"出售", "出售"},
+		{"枪", "枪"},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+	if res[0].Start != len("禁止吸毒，拒绝*吸毒，他在") {
+		t.Fatalf("Unexpected start of the kept match: %d", res[0].Start)
+	}
+}
+
+func TestTrieWriter_InsertEntry(t *testing.T) {
+	type payload struct {
+		action string
+	}
+	obj := NewSearch(SetPinyin(true))
+	obj.TrieWriter().
+		InsertEntry(Entry{Word: "他*妈的", Category: "辱骂", Level: 2, ID: 7, Payload: payload{"block"}}).
+		InsertEntry(Entry{Word: "ass", Category: "辱骂", Level: 1, Boundary: true}).
+		InsertWords([]string{"赌博"}).
+		BuildFail()
+
+	str := "tamade，class ass，赌博"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word     string
+		origin   string
+		category string
+		level    int
+		id       uint64
+	}
+
+	wants := []wantPair{
+		{"他妈的", "他*妈的", "辱骂", 2, 7},
+		{"ass", "ass", "辱骂", 1, 0},
+		{"赌博", "赌博", "", 0, 0},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Origin != want.origin || v.Category != want.category || v.Level != want.level || v.ID != want.id {
+			t.Fatalf("Unexpected metadata of sensitive word：%s, result: %+v", want.word, *v)
+		}
+	}
+
+	if p, ok := PayloadOf[payload](res[0]); !ok || p.action != "block" {
+		t.Fatalf("Unexpected payload: %+v", res[0].Payload)
+	}
+	if _, ok := PayloadOf[payload](res[2]); ok {
+		t.Fatalf("Unexpected payload: %+v", res[2].Payload)
+	}
+}
+
+func TestTrieWriter_SetPattern(t *testing.T) {
+	obj := NewSearch(SetSkip("*"))
+	obj.TrieWriter().
+		SetPattern(true).
+		InsertWords([]string{"傻?逼", "[操草艹]你妈", "笨{0,3}蛋", "a\\?b", "坏[", "好人"}).
+		BuildFail()
+
+	if obj.TrieWriter().Size() != 6 {
+		t.Fatalf("Unexpected size of patterns: %d", obj.TrieWriter().Size())
+	}
+
+	str := "傻逼，傻*瓜逼，艹你妈，笨笨了个蛋，a?b，坏[，好人"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+	}
+
+	wants := []wantPair{
+		{"傻?逼", "傻*瓜逼"},
+		{"[操草艹]你妈", "艹你妈"},
+		{"笨{0,3}蛋", "笨笨了个蛋"},
+		{"a\\?b", "a?b"},
+		{"坏[", "坏["},
+		{"好人", "好人"},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+
+	if got := string(obj.Replace([]byte("傻了逼"), '*')); got != "*********" {
+		t.Fatalf("Unexpected replacement: %s", got)
+	}
+
+	// 多个间隔的模式在大量重复字符中匹配时，每个字符的开销不会随间隔数成倍增长
+	gaps := NewSearch(SetSkip(""))
+	gaps.TrieWriter().SetPattern(true).InsertWords([]string{"a{0,32}a{0,32}a{0,32}a{0,32}a{0,32}a{0,32}b"}).BuildFail()
+	text := strings.Repeat(strings.Repeat("a", 300)+"b", 20)
+	res = gaps.Find([]byte(text))
+	if len(res) != 20 || res[0].Start != 300-6*32-6 || res[0].End != 300 {
+		t.Fatalf("Unexpected result with gaps: %d, %v", len(res), res[0])
+	}
+}
+
+func TestSearch_AddRegexp(t *testing.T) {
+	obj := Strings([]string{"加q", "代购"})
+	if err := obj.AddRegexps([]string{`v信\d{5,}`, `加[qQ扣]\s*\d{6,}`}); err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.AddRegexp(`(`); err == nil {
+		t.Fatalf("Invalid regular expression should return an error")
+	}
+
+	str := "代购加q 123456，加q，v信12345"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+	}
+
+	wants := []wantPair{
+		{"代购", "代购"},
+		{`加[qQ扣]\s*\d{6,}`, "加q 123456"},
+		{"加q", "加q"},
+		{`v信\d{5,}`, "v信12345"},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+
+	if !obj.HasSens([]byte("v信88888")) {
+		t.Fatalf("HasSens should report regular expression matches")
+	}
+	if got := string(obj.Replace([]byte("v信88888!"), '*')); got != "*********!" {
+		t.Fatalf("Unexpected replacement: %s", got)
+	}
+}
+
+func TestSearch_AddCombo(t *testing.T) {
+	obj := NewSearch()
+	obj.TrieWriter().InsertEntry(Entry{Word: "枪支", Category: "weapon", Level: 3}).BuildFail()
+	err := obj.ComboStrings([]string{
+		"出售&枪支~20",
+		"",
+		"冰|麻古|K粉/2",
+		"微信>转账~5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseComboRule("出售&枪支|弹药"); err == nil {
+		t.Fatalf("Mixed combo operators should return an error")
+	}
+
+	str := "枪支出售，出售玩具，先转账再加微信，加微信后转账，冰和麻古"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+	}
+
+	wants := []wantPair{
+		{"枪支", "枪支"},
+		{"出售&枪支~20", "枪支出售"},
+		{"微信>转账~5", "微信后转账"},
+		{"冰|麻古|K粉/2", "冰和麻古"},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+	if len(res[3].Parts) != 2 || res[3].Parts[1].Word != "麻古" {
+		t.Fatalf("Unexpected parts of combo result: %v", res[3].Parts)
+	}
+	if parts := res[1].Parts; len(parts) != 2 || parts[0].Category != "weapon" || parts[0].Level != 3 || parts[1].Category != "" {
+		t.Fatalf("Parts that are dictionary words should carry their metadata: %v", parts)
+	}
+
+	if obj.HasSens([]byte("出售玩具")) {
+		t.Fatalf("A single combo word should not be sensitive")
+	}
+	if got := string(obj.ReplaceRune([]byte("冰和麻古"), '*')); got != "*和**" {
+		t.Fatalf("Unexpected replacement: %s", got)
+	}
+}
+
+func TestSearch_FindNoise(t *testing.T) {
+	obj := NewSearch(SetNoise(2), SetMaxSkip(3))
+	obj.TrieWriter().
+		InsertWords([]string{"空子", "霸王龙"}).
+		InsertEntry(Entry{Word: "是我", Noise: -1}).
+		InsertEntry(Entry{Word: "垃圾", Noise: 4}).
+		BuildFail()
+
+	str := "我空ss子sss，霸*王xyz龙，是ab我，垃abcd圾，空.........子，霸王*..龙"
+	res := obj.Find([]byte(str))
+
+	type wantPair struct {
+		word    string
+		matched string
+	}
+
+	wants := []wantPair{
+		{"空子", "空ss子"},
+		{"垃圾", "垃abcd圾"},
+		{"霸王龙", "霸王*..龙"},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+}
+
+func TestSearch_FindAll(t *testing.T) {
+	words := []string{"林茹", "林如", "临蓐", "空子", "霸王龙", "我是个SB", "是我", "TMD", "他妈的", "他妈"}
+	obj := Strings(words)
+
+	str := []byte("我空ss子sss我是霸**王*龙,我是我我是个(S)(B)真的,TMD，他妈的")
+	res := obj.FindAll(str)
+
+	type wantPair struct {
+		word    string
+		matched string
+	}
+
+	wants := []wantPair{
+		{"霸王龙", "霸**王*龙"},
+		{"是我", "是我"},
+		{"我是个SB", "我是个(S)(B"},
+		{"TMD", "TMD"},
+		{"他妈的", "他妈的"},
+		{"他妈", "他妈"},
+	}
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.word || v.Matched != want.matched {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want.word, v)
+		}
+	}
+
+	if got := string(obj.Replace(str, '*')); got != "我空ss子sss我是************,我********************)真的,***，*********" {
+		t.Fatalf("Replace should keep the non-overlapping result: %s", got)
+	}
+}
+
+func TestSearch_SetStrategy(t *testing.T) {
+	words := []string{"他妈的", "他妈的啊", "他妈", "的啊你"}
+	str := []byte("他妈的啊你")
+
+	type wantPair struct {
+		strategy MatchStrategy
+		matched  []string
+		replaced string
+	}
+
+	wants := []wantPair{
+		{LeftmostLongest, []string{"他妈的啊"}, "****你"},
+		{LeftmostFirst, []string{"他妈的"}, "***啊你"},
+		{Shortest, []string{"他妈", "的啊你"}, "*****"},
+	}
+
+	for _, want := range wants {
+		obj := NewSearch(SetStrategy(want.strategy))
+		obj.TrieWriter().InsertWords(words).BuildFail()
+		res := obj.Find(str)
+		if len(want.matched) != len(res) {
+			t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(want.matched), len(res))
+		}
+		for i, v := range res {
+			if v.Matched != want.matched[i] {
+				t.Fatalf("Unable to match sensitive word：%s, result: %s", want.matched[i], v)
+			}
+		}
+		if got := string(obj.ReplaceRune(str, '*')); got != want.replaced {
+			t.Fatalf("Unexpected replacement with strategy %d: %s", want.strategy, got)
+		}
+		if !obj.HasSens(str) {
+			t.Fatalf("HasSens should find sensitive words with strategy %d", want.strategy)
+		}
+	}
+}
+
+func TestScanner_Write(t *testing.T) {
+	obj := NewSearch(SetCaseFold(true), SetNoise(1))
+	obj.TrieWriter().
+		InsertWords([]string{"霸王龙", "我是个SB", "是我", "TMD", "他妈的", "他妈"}).
+		InsertBoundary("ass").
+		InsertContext("吸毒", ContextRule{Text: "可耻", After: 3}).
+		BuildFail()
+
+	str := []byte("我是霸**王*龙,我是我我是个(S)(B)真的,tmd，他妈的ass class,吸毒可耻，吸毒很爽，他x妈")
+	wants := obj.Find(str)
+
+	for size := 1; size <= 4; size++ {
+		scanner := obj.NewScanner()
+		var res []*Result
+		for i := 0; i < len(str); i += size {
+			end := i + size
+			if end > len(str) {
+				end = len(str)
+			}
+			got := scanner.Write(str[i:end])
+			for _, r := range got {
+				if r.Start < scanner.Safe() && r.End >= scanner.Safe() {
+					t.Fatalf("Safe offset %d is inside a result: %s", scanner.Safe(), r)
+				}
+			}
+			if scanner.Safe() > scanner.Offset() {
+				t.Fatalf("Safe offset %d exceeds written bytes %d", scanner.Safe(), scanner.Offset())
+			}
+			res = append(res, got...)
+		}
+		res = append(res, scanner.Flush()...)
+
+		if len(wants) != len(res) {
+			t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+		}
+		for i, v := range res {
+			want := wants[i]
+			if v.Word != want.Word || v.Matched != want.Matched || v.Start != want.Start || v.End != want.End {
+				t.Fatalf("Unable to match sensitive word：%s, result: %s", want, v)
+			}
+		}
+	}
+}
+
+func TestScanner_WriteRunes(t *testing.T) {
+	pattern := NewSearch(SetWordBoundary(true))
+	pattern.TrieWriter().SetPattern(true).Insert("[他妈]{0,2}的").BuildFail()
+
+	obj := NewSearch(SetWordBoundary(true), SetNoise(1))
+	obj.TrieWriter().
+		SetPattern(true).Insert("[他妈]{0,2}的").SetPattern(false).
+		InsertWords([]string{"卧槽", "ab"}).
+		InsertContext("他妈", ContextRule{Text: "x", After: 2}).
+		InsertContext("ba", ContextRule{Text: "的", Before: 2, Require: true}).
+		BuildFail()
+
+	for _, obj := range []*Search{pattern, obj} {
+		for _, str := range []string{"他他妈的的的", "他妈的他妈x的ab", "的ba卧*槽,ab a b他妈", "的的ba他x妈x的", "卧卧槽槽槽的的"} {
+			checkScannerRunes(t, obj, str)
+		}
+	}
+}
+
+// checkScannerRunes 检查每次只写入一个字符时 Scanner 的结果与 Find 相同
+func checkScannerRunes(t *testing.T, obj *Search, str string) {
+	wants := obj.Find([]byte(str))
+	scanner := obj.NewScanner()
+	var res []*Result
+	for _, r := range str { // 每次只写入一个字符
+		res = append(res, scanner.WriteString(string(r))...)
+	}
+	res = append(res, scanner.Flush()...)
+
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+	for i, v := range res {
+		want := wants[i]
+		if v.Word != want.Word || v.Matched != want.Matched || v.Start != want.Start || v.End != want.End {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", want, v)
+		}
+	}
+}
+
+type stopWriter struct {
+	DefaultResultWriter
+	stopAt string
+}
+
+func (_this *stopWriter) Write(res *Result) (stop bool) {
+	_this.DefaultResultWriter.Write(res)
+	return res.Word == _this.stopAt
+}
+
+func TestSearch_FindTo(t *testing.T) {
+	words := []string{"林茹", "林如", "临蓐", "空子", "霸王龙", "我是个SB", "是我", "TMD", "他妈的", "他妈"}
+	obj := Strings(words)
+	str := []byte("我空ss子sss我是霸**王*龙,我是我我是个(S)(B)真的,TMD，他妈的")
+
+	w := &stopWriter{stopAt: "是我"}
+	obj.FindTo(str, w)
+
+	wants := []string{"霸王龙", "是我"}
+	if len(wants) != w.Len() {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), w.Len())
+	}
+	for i, v := range w.List() {
+		if v.Word != wants[i] {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", wants[i], v)
+		}
+	}
+
+	if res := obj.FindN(str, 3); len(res) != 3 || res[2].Word != "我是个SB" {
+		t.Fatalf("Unexpected results of FindN: %v", res)
+	}
+	if n := obj.Count(str); n != len(obj.Find(str)) {
+		t.Fatalf("Unexpected count of sensitive words: %d", n)
+	}
+	if obj.HasSens([]byte("我空ss子sss")) {
+		t.Fatalf("Unexpected sensitive word")
+	}
+}
+
+func TestSearch_SetPosition(t *testing.T) {
+	obj := NewSearch(SetPosition(true))
+	obj.TrieWriter().InsertWords([]string{"𠮷野", "霸王龙", "家𠮷"}).BuildFail()
+	str := []byte("ab\n𠮷野家𠮷\nxx霸*王龙")
+
+	wants := []Position{
+		{RuneStart: 3, RuneEnd: 4, UTF16Start: 3, UTF16End: 5, Line: 2, Column: 1, EndLine: 2, EndColumn: 2},
+		{RuneStart: 5, RuneEnd: 6, UTF16Start: 6, UTF16End: 8, Line: 2, Column: 3, EndLine: 2, EndColumn: 4},
+		{RuneStart: 10, RuneEnd: 13, UTF16Start: 12, UTF16End: 15, Line: 3, Column: 3, EndLine: 3, EndColumn: 6},
+	}
+	res := obj.Find(str)
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+	for i, v := range res {
+		if v.Pos == nil || *v.Pos != wants[i] {
+			t.Fatalf("Unexpected position of sensitive word：%s, wants: %+v, result: %+v", v.Word, wants[i], v.Pos)
+		}
+	}
+
+	plain := Strings([]string{"霸王龙"})
+	if res := plain.Find(str); len(res) != 1 || res[0].Pos != nil {
+		t.Fatalf("Unexpected position without SetPosition: %v", res)
+	}
+	if res := plain.WithPosition().Find(str); len(res) != 1 || res[0].Pos == nil || *res[0].Pos != wants[2] {
+		t.Fatalf("Unexpected position of WithPosition: %v", res)
+	}
+}
+
+func TestSearch_FindLongWord(t *testing.T) {
+	long := strings.Repeat("为人民服务", 30) + "霸王龙"
+	obj := NewSearch(SetSortedSkip("!*"))
+	obj.TrieWriter().InsertWords([]string{long, "霸王龙", strings.Repeat("龙", 300)}).BuildFail()
+
+	noisy := strings.Replace(long, "人民", "人*民", -1)
+	str := []byte("前面" + noisy + "后面!" + strings.Repeat("龙*", 300))
+	res := obj.Find(str)
+
+	type wantPair struct {
+		word    string
+		matched string
+		start   int
+	}
+	wants := []wantPair{
+		{long, noisy, len("前面")},
+		{strings.Repeat("龙", 300), strings.Repeat("龙*", 299) + "龙", len("前面" + noisy + "后面!")},
+	}
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+	for i, v := range res {
+		if v.Word != wants[i].word || v.Matched != wants[i].matched || v.Start != wants[i].start {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", wants[i].word, v)
+		}
+	}
+
+	writer := NewTrieWriter()
+	writer.setSkip(&Skip{})
+	_, err := writer.insertReader(strings.NewReader("TMD\n"+long+"\n他妈的"), make([]byte, 16), '\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"TMD", long, "他妈的"}
+	array := writer.Array()
+	sort.Strings(array)
+	sort.Strings(want)
+	if strings.Join(want, "\n") != strings.Join(array, "\n") {
+		t.Fatalf("Incorrect sensitive words: %v", array)
+	}
+}
+
+func TestTrieWriter_Report(t *testing.T) {
+	writer := NewTrieWriter()
+	skipper := &Skip{}
+	skipper.Set("*!")
+	writer.setSkip(skipper).SetMaxWordLen(20)
+	_, err := writer.InsertReader(strings.NewReader("TMD\n\n**\n\xff\xfe\n"+strings.Repeat("霸王龙", 10)+"\n他妈的\n"), '\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := writer.Report()
+	if report.Lines != 6 || report.Inserted != 2 {
+		t.Fatalf("Unexpected load report: %+v", report)
+	}
+	wants := []SkippedLine{
+		{3, "**", SkipEmpty},
+		{4, "\xff\xfe", SkipInvalidUTF8},
+		{5, strings.Repeat("霸王龙", 10), SkipTooLong},
+	}
+	if len(wants) != len(report.Skipped) {
+		t.Fatalf("The number of skipped lines is incorrect.wants len:%d,result len:%d", len(wants), len(report.Skipped))
+	}
+	for i, v := range report.Skipped {
+		if v != wants[i] {
+			t.Fatalf("Unexpected skipped line: %+v, wants: %+v", v, wants[i])
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader("TMD\n" + strings.Repeat("霸王龙", 10)))
+	scanner.Buffer(make([]byte, 16), 16)
+	var loadErr *LoadError
+	if err = writer.InsertScanner(scanner); !errors.As(err, &loadErr) || loadErr.Line != 2 || !errors.Is(err, bufio.ErrTooLong) {
+		t.Fatalf("Unexpected error of InsertScanner: %v", err)
+	}
+
+	if _, err = File("./example/not-exists"); !errors.As(err, &loadErr) || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Unexpected error of File: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/words" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("TMD\n!!\n他妈的"))
+	}))
+	defer server.Close()
+	if _, err = Network(server.URL + "/missing"); !errors.Is(err, ErrStatus) {
+		t.Fatalf("Unexpected error of Network: %v", err)
+	}
+	obj, err := Network(server.URL + "/words")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report = obj.TrieWriter().Report()
+	if report.Source != server.URL+"/words" || report.Inserted != 2 || len(report.Skipped) != 1 || report.Skipped[0].Line != 2 {
+		t.Fatalf("Unexpected load report: %+v", report)
+	}
+}
+
+func TestSearch_FindEncoding(t *testing.T) {
+	gbk := func(s string) []byte {
+		b, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(s))
+		return b
+	}
+	writer := NewTrieWriter()
+	writer.setSkip(&Skip{list: []rune(sortedSkipList)})
+	if _, err := writer.InsertReaderEncoding(bytes.NewReader(gbk("霸王龙\nTMD\n他妈的")), simplifiedchinese.GBK, '\n'); err != nil {
+		t.Fatal(err)
+	}
+	writer.BuildFail()
+	obj := NewSearch(SetWriter(writer))
+
+	// 无法解码的行不会被插入
+	bad := NewTrieWriter()
+	bad.setSkip(&Skip{})
+	if _, err := bad.InsertReaderEncoding(bytes.NewReader(append(gbk("TMD\n"), "\x81 \n"...)), simplifiedchinese.GBK, '\n'); err != nil {
+		t.Fatal(err)
+	}
+	report := bad.Report()
+	if report.Inserted != 1 || len(report.Skipped) != 1 || report.Skipped[0].Line != 2 || report.Skipped[0].Reason != SkipInvalidUTF8 {
+		t.Fatalf("Unexpected load report: %+v", report)
+	}
+	if bad.Size() != 1 {
+		t.Fatalf("Unexpected words: %v", bad.Array())
+	}
+
+	str := gbk("我是霸**王龙,TMD真的")
+	res, err := obj.FindEncoding(str, simplifiedchinese.GBK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type wantPair struct {
+		word       string
+		start, end int
+	}
+	wants := []wantPair{{"霸王龙", 4, 11}, {"TMD", 13, 15}}
+	if len(wants) != len(res) {
+		t.Fatalf("The number of matched sensitive words is incorrect.wants len:%d,result len:%d", len(wants), len(res))
+	}
+	for i, v := range res {
+		if v.Word != wants[i].word || v.Start != wants[i].start || v.End != wants[i].end {
+			t.Fatalf("Unable to match sensitive word：%s, result: %s", wants[i].word, v)
+		}
+	}
+
+	if data, err := obj.ReplaceEncoding(str, simplifiedchinese.GBK, '*'); err != nil || !bytes.Equal(data, gbk("我是********,***真的")) {
+		t.Fatalf("Unexpected replace result: %q, %v", data, err)
+	}
+	if data, err := obj.ReplaceRuneEncoding(str, simplifiedchinese.GBK, '〇'); err != nil || !bytes.Equal(data, gbk("我是〇〇〇〇〇,〇〇〇真的")) {
+		t.Fatalf("Unexpected replace result: %q, %v", data, err)
+	}
+
+	gb18030, _ := simplifiedchinese.GB18030.NewEncoder().Bytes([]byte("😀霸王龙"))
+	if res, err = obj.FindEncoding(gb18030, simplifiedchinese.GB18030); err != nil || len(res) != 1 || res[0].Start != 4 || res[0].End != 9 {
+		t.Fatalf("Unexpected results of gb18030 text: %v, %v", res, err)
+	}
+
+	big5, _ := traditionalchinese.Big5.NewEncoder().Bytes([]byte("這是霸王龍"))
+	obj = Strings([]string{"霸王龍"})
+	if res, err = obj.FindEncoding(big5, traditionalchinese.Big5); err != nil || len(res) != 1 || res[0].Start != 4 || res[0].End != 9 {
+		t.Fatalf("Unexpected results of big5 text: %v, %v", res, err)
+	}
+}
+
+func TestSearch_SetInvalidUTF8(t *testing.T) {
+	words := []string{"霸王龙", "�"}
+	str := []byte("a\xffb霸\xff王龙\xe9\x9c")
+
+	obj := NewSearch(SetSortedSkip(""))
+	obj.TrieWriter().InsertWords(words).BuildFail()
+	if res := obj.Find(str); len(res) != 0 {
+		t.Fatalf("Unexpected results of invalid bytes: %v", res)
+	}
+	if data := obj.Replace(str, '*'); string(data) != "a�b霸�王龙�" {
+		t.Fatalf("Unexpected replace result: %q", data)
+	}
+
+	obj = NewSearch(SetSortedSkip(""), SetInvalidUTF8(InvalidSkip))
+	obj.TrieWriter().InsertWords(words).BuildFail()
+	res := obj.Find(str)
+	if len(res) != 1 || res[0].Word != "霸王龙" || res[0].Start != 3 || res[0].End != 12 {
+		t.Fatalf("Unexpected results of skipped invalid bytes: %v", res)
+	}
+	if data := obj.Replace(str, '*'); string(data) != "a�b**********�" {
+		t.Fatalf("Unexpected replace result: %q", data)
+	}
+	if data := obj.ReplaceRune(str, '*'); string(data) != "a�b****�" {
+		t.Fatalf("Unexpected replace result: %q", data)
+	}
+
+	obj = NewSearch(SetSortedSkip(""), SetInvalidUTF8(InvalidReject))
+	obj.TrieWriter().InsertWords(words).BuildFail()
+	var invalid *InvalidUTF8Error
+	if _, err := obj.TryFind(str); !errors.As(err, &invalid) || invalid.Offset != 1 {
+		t.Fatalf("Unexpected error of TryFind: %v", err)
+	}
+	if _, err := obj.TryReplace(str, '*'); err == nil {
+		t.Fatalf("Unexpected result of TryReplace")
+	}
+	if _, err := obj.TryHasSens(str); !errors.As(err, &invalid) {
+		t.Fatalf("Unexpected error of TryHasSens: %v", err)
+	}
+	if _, err := obj.TryReplaceFunc(str, FixedMask('*', 3)); !errors.As(err, &invalid) {
+		t.Fatalf("Unexpected error of TryReplaceFunc: %v", err)
+	}
+	if has, err := obj.TryHasSens([]byte("是霸王龙")); err != nil || !has {
+		t.Fatalf("Unexpected result of TryHasSens: %v, %v", has, err)
+	}
+	if data, err := obj.TryReplaceRune([]byte("是霸王龙"), '*'); err != nil || string(data) != "是***" {
+		t.Fatalf("Unexpected replace result: %q, %v", data, err)
+	}
+
+	// 不返回错误的方法同样拒绝含有非法字节的文本
+	str = []byte("霸王龙\xff")
+	if res := obj.Find(str); len(res) != 0 || obj.HasSens(str) || obj.Count(str) != 0 {
+		t.Fatalf("Unexpected results of rejected text: %v", res)
+	}
+	if data := obj.Replace(str, '*'); string(data) != string(str) {
+		t.Fatalf("Rejected text should be unchanged: %q", data)
+	}
+	if data := obj.ReplaceRune(str, '*'); string(data) != string(str) {
+		t.Fatalf("Rejected text should be unchanged: %q", data)
+	}
+	scanner := obj.NewScanner()
+	if res := scanner.WriteString("是霸王"); len(res) != 0 {
+		t.Fatalf("Unexpected results of scanner: %v", res)
+	}
+	if res := append(scanner.Write([]byte("龙\xff是")), scanner.Flush()...); len(res) != 0 || !errors.As(scanner.Err(), &invalid) || invalid.Offset != 12 {
+		t.Fatalf("Unexpected results of rejected scanner: %v, %v", res, scanner.Err())
+	}
+	scanner.Reset()
+	if res := append(scanner.WriteString("霸王龙"), scanner.Flush()...); len(res) != 1 || scanner.Err() != nil {
+		t.Fatalf("Unexpected results of scanner after reset: %v", res)
+	}
+
+	// 替换为非 ASCII 字节时不能被当作非法字节处理
+	obj = Strings([]string{"他妈"})
+	if data := obj.Replace([]byte("a他妈\xff"), 0xAA); string(data) != "a\xaa\xaa\xaa\xaa\xaa\xaa\uFFFD" {
+		t.Fatalf("Unexpected replace result: %q", data)
+	}
+	if data := obj.ReplaceFunc([]byte("他妈"), FixedToken("\xaa")); string(data) != "\xaa" {
+		t.Fatalf("Unexpected replace result: %q", data)
+	}
+}
+
+func TestSearch_ReplaceFunc(t *testing.T) {
+	obj := NewSearch(SetSortedSkip("*"))
+	obj.TrieWriter().InsertEntries([]Entry{
+		{Word: "霸王龙", Category: "animal"},
+		{Word: "TMD", Category: "abuse"},
+		{Word: "SB", Category: "abuse"},
+		{Word: "草"},
+	})
+	obj.TrieWriter().BuildFail()
+	str := []byte("我是霸**王龙,TMD,SB,草")
+
+	type wantPair struct {
+		name string
+		fn   func(*Result) []byte
+		want string
+	}
+	wants := []wantPair{
+		{"FixedToken", FixedToken("[censored]"), "我是[censored],[censored],[censored],[censored]"},
+		{"KeepEnds", KeepEnds('*'), "我是霸***龙,T*D,S*,*"},
+		{"ByCategory", ByCategory(map[string]func(*Result) []byte{"abuse": FixedMask('x', 2)}, nil), "我是霸**王龙,xx,xx,草"},
+		{"FixedMask", FixedMask('*', 3), "我是***,***,***,***"},
+	}
+	for _, v := range wants {
+		if data := obj.ReplaceFunc(str, v.fn); string(data) != v.want {
+			t.Fatalf("Unexpected replace result of %s: %s, wants: %s", v.name, data, v.want)
+		}
+	}
+}
+
+func TestSearch_ReplaceFuncOverlap(t *testing.T) {
+	obj := Strings([]string{"枪支弹药"})
+	if err := obj.AddCombo(ComboRule{Words: []string{"出售", "枪支"}, Kind: ComboAll}); err != nil {
+		t.Fatal(err)
+	}
+	str := []byte("我出售枪支弹药啊")
+
+	if data := obj.ReplaceFunc(str, KeepEnds('*')); string(data) != "我出*枪**药啊" {
+		t.Fatalf("Unexpected replace result: %s", data)
+	}
+	var words []string
+	obj.ReplaceFunc(str, func(res *Result) []byte {
+		words = append(words, res.Word+":"+res.Matched)
+		return nil
+	})
+	if strings.Join(words, ",") != "出售:出售,枪支弹药:枪支弹药" {
+		t.Fatalf("Unexpected merged results: %v", words)
+	}
+	if data := obj.Replace(str, '*'); string(data) != "我"+strings.Repeat("*", 18)+"啊" {
+		t.Fatalf("Unexpected replace result: %s", data)
+	}
+}
@@ -0,0 +1,92 @@
+package sensfilter
+
+// Scanner 是在 Search 之上的流式扫描器，用于分多次收到的文本（如聊天消息流、大模型逐个输出的 token）。
+// 写入之间保留自动机的状态，因此被拆分到两次写入中的敏感词也能匹配到，结果中的位置是从整个文本开头算起的字节位置。
+// 正则表达式规则和组合规则需要完整的文本，Scanner 不会匹配这些规则。Scanner 不是并发安全的。
+// 使用 InvalidReject 时，写入的文本中出现非法的 UTF-8 字节后不再返回任何结果，Err 返回 *InvalidUTF8Error，
+// 之前的写入已经返回的结果无法撤回，需要由调用方丢弃。
+type Scanner struct {
+	search *Search
+	state  *scanState
+	list   *DefaultResultWriter // 已经确定还没有返回的结果
+	offset int                  // 已经写入的字节数
+}
+
+// NewScanner 返回一个使用当前搜索器规则的流式扫描器
+func (_this *Search) NewScanner() *Scanner {
+	scanner := &Scanner{search: _this}
+	scanner.Reset()
+	return scanner
+}
+
+// Write 写入一段文本，返回写入后已经可以确定的结果，还可能与之后的文本组成敏感词的部分会等到之后的写入或 Flush 时再返回
+func (_this *Scanner) Write(chunk []byte) []*Result {
+	st := _this.state
+	_this.offset += len(chunk)
+	if st.err != nil { // 文本已经被拒绝
+		return nil
+	}
+	st.buf = append(st.buf, chunk...)
+	st.feed()
+	if st.err != nil { // 文本被拒绝，丢弃还没有返回的结果
+		_this.list.list = nil
+		return nil
+	}
+	return _this.take()
+}
+
+// WriteString 与 Write 相同，写入的是字符串
+func (_this *Scanner) WriteString(chunk string) []*Result {
+	return _this.Write([]byte(chunk))
+}
+
+// Flush 表示文本已经全部写入，返回剩余的所有结果，之后写入的文本作为新的文本重新开始扫描，位置从 0 开始。
+// 文本被拒绝（Err 不为nil）时返回nil，并且保留错误，需要调用 Reset 才能开始新的文本
+func (_this *Scanner) Flush() []*Result {
+	st := _this.state
+	st.final = true
+	st.feed()
+	if st.err != nil {
+		_this.list.list = nil
+		return nil
+	}
+	results := _this.list.List()
+	_this.Reset()
+	return results
+}
+
+// Err 返回使用 InvalidReject 时写入的文本中第一个非法 UTF-8 字节的错误（*InvalidUTF8Error），没有时返回nil
+func (_this *Scanner) Err() error {
+	return _this.state.err
+}
+
+// Safe 返回可以安全输出到下游的文本长度，文本中在此之前的部分不会再出现新的结果（已经返回的结果需要先处理），
+// 之后的部分还可能与之后写入的文本组成敏感词，需要暂时保留
+func (_this *Scanner) Safe() int {
+	return _this.state.safe()
+}
+
+// Offset 返回当前文本已经写入的字节数
+func (_this *Scanner) Offset() int {
+	return _this.offset
+}
+
+// Reset 丢弃已经写入的文本和状态，重新开始扫描新的文本
+func (_this *Scanner) Reset() {
+	_this.list = &DefaultResultWriter{}
+	_this.state = _this.search.newScanState(_this.list, false)
+	_this.offset = 0
+}
+
+// take 取出已经确定的结果，并丢弃之后不会再使用的文本
+func (_this *Scanner) take() []*Result {
+	st := _this.state
+	results := _this.list.List()
+	_this.list.list = nil
+	history := st.search.trieWriter.maxBefore // 检查单词边界至少需要之前的一个字符
+	if history < 1 {
+		history = 1
+	}
+	st.trim(history)
+	return results
+}
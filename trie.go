@@ -6,15 +6,63 @@ import (
 	"errors"
 	"io"
 	"os"
-	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+const (
+	allowPhrase  uint8 = iota + 1 // 白名单短语的结尾节点
+	allowVariant                  // 白名单短语变体（如拼音）的结尾节点
 )
 
-// trie表示trie树中的每个节点，具有next、fail、len和end四个属性。
+// trie表示trie树中的每个节点，具有next、fail、out、term、len、end、allow和combo八个属性。
 type trie struct {
-	next map[rune]*trie // 映射表，用于存储下一个字符的节点
-	fail *trie          // 指向该节点的失败指针
-	len  uint8          // 表示该节点代表的字符串长度
-	end  bool           // 表示是否是一个单词的结尾节点
+	next  map[rune]*trie // 映射表，用于存储下一个字符的节点
+	fail  *trie          // 指向该节点的失败指针
+	out   *trie          // 沿失败指针链找到的最近的输出节点（见 output），用于找出以当前位置结尾的所有单词
+	term  *terminal      // 结尾节点的属性，其他节点为nil
+	len   int            // 表示该节点代表的字符串长度（字符数），单词长度没有限制
+	end   bool           // 表示是否是一个单词的结尾节点
+	allow uint8          // 表示是否是白名单短语（或其变体）的结尾节点
+	combo bool           // 表示是否是组合规则中单词（或其变体）的结尾节点
+}
+
+// terminal 保存只有结尾节点才需要的属性，大多数节点不是结尾节点，单独保存可以减少trie树占用的内存
+type terminal struct {
+	word   string        // 结尾节点对应的单词或白名单短语（已去除跳过字符，未做归一化）
+	meta   *Entry        // 单词的元数据，没有元数据并且原文与word相同时为nil
+	origin *trie         // 变体（如拼音）结尾节点对应的原单词结尾节点，变体不计入单词数量
+	abbr   bool          // 表示变体是否是原单词的拼音首字母缩写
+	strict bool          // 表示该单词（或由字母组成的缩写）是否总是只在单词边界处匹配
+	rules  []contextRule // 该单词的上下文例外规则
+	seq    int           // 单词的插入顺序，用于 LeftmostFirst 规则
+}
+
+// terminal 返回结尾节点的属性，还没有时创建
+func (n *trie) terminal() *terminal {
+	if n.term == nil {
+		n.term = &terminal{}
+	}
+	return n.term
+}
+
+// entry 返回结尾节点对应的原单词的属性，单词的属性都保存在原单词结尾节点上
+func (n *trie) entry() *terminal {
+	if origin := n.term.origin; origin != nil {
+		return origin.term
+	}
+	return n.term
+}
+
+// bounded 返回匹配到该节点时是否总是只在单词边界处匹配，原单词要求时它的所有变体同样要求
+func (n *trie) bounded() bool {
+	return n.term.strict || n.entry().strict
+}
+
+// output 返回节点是否是一个单词、白名单短语或组合规则中单词的结尾节点
+func (n *trie) output() bool {
+	return n.end || n.allow != 0 || n.combo
 }
 
 // NewTrieWriter 返回一个新的TrieWriter对象，其中tireRoot属性为一个空的trie树根节点。
@@ -24,14 +72,59 @@ func NewTrieWriter() *TrieWriter {
 
 // TrieWriter 表示一个Trie写入器，包含了一些trie树的相关操作。
 type TrieWriter struct {
-	size     int   // trie树中单词的数量
-	skip     *Skip // 需要跳过的字符集合
-	tireRoot *trie // trie树根节点
+	size        int         // trie树中单词的数量
+	skip        *Skip       // 需要跳过的字符集合
+	norm        normalizer  // 插入和搜索时对字符的归一化处理
+	abbrMin     int         // 生成拼音首字母缩写的最少字数，0表示不生成
+	allow       bool        // 是否是写入白名单短语的TrieWriter
+	pattern     bool        // 插入单词时是否解析模式语法
+	patterns    *patternSet // 模式单词集合，没有模式单词时为nil
+	noise       int         // 默认允许在单词相邻两个字符之间插入的非跳过字符数
+	noisy       *noiseSet   // 允许插入字符的单词集合，没有这样的单词时为nil
+	seq         int         // 下一个插入的单词的插入顺序
+	maxBefore   int         // 所有上下文例外规则中最大的 Before
+	maxWordLen  int         // 加载时单词的最大字节数，0表示不限制
+	report      *LoadReport // 最近一次加载的报告
+	allowWriter *TrieWriter // 共用同一棵trie树的白名单TrieWriter
+	tireRoot    *trie       // trie树根节点
 }
 
 // setSkip设置需要跳过的字符集合，并返回当前对象。
 func (t *TrieWriter) setSkip(skip *Skip) *TrieWriter {
 	t.skip = skip
+	if t.allowWriter != nil {
+		t.allowWriter.skip = skip
+	}
+	return t
+}
+
+// setNormalizer设置字符的归一化处理，需要在插入单词之前设置，并返回当前对象。
+func (t *TrieWriter) setNormalizer(norm normalizer) *TrieWriter {
+	t.norm = norm
+	if t.allowWriter != nil {
+		t.allowWriter.norm = norm
+	}
+	return t
+}
+
+// Allow 返回写入白名单短语的TrieWriter，两者共用同一棵trie树、跳过字符和归一化处理。通过它插入的短语（如 "打飞机场"）
+// 会抵消被其完全覆盖的敏感词匹配，所有插入方法都可以使用，Size、Array 返回的是白名单短语。插入之后同样需要调用 BuildFail。
+func (t *TrieWriter) Allow() *TrieWriter {
+	if t.allow {
+		return t
+	}
+	if t.allowWriter == nil {
+		t.allowWriter = &TrieWriter{skip: t.skip, norm: t.norm, allow: true, tireRoot: t.tireRoot}
+	}
+	return t.allowWriter
+}
+
+// SetAbbreviation 设置插入全部由汉字组成的单词时，同时插入其拼音首字母缩写（如 "他妈的" 的 "tmd"），
+// 只有字数不少于 minLen 的单词才会生成缩写，以免过短的缩写产生大量误判，minLen 为 0 时不生成缩写。
+// 匹配到缩写时结果中的 Word 为原单词，Abbr 为 true。由字母组成的缩写只在单词边界处匹配，不会匹配更长的英文单词中的一段（如 "utmdx" 中的 "tmd"）。
+// 需要在插入单词之前设置，返回当前对象。
+func (t *TrieWriter) SetAbbreviation(minLen int) *TrieWriter {
+	t.abbrMin = minLen
 	return t
 }
 
@@ -47,24 +140,147 @@ func (t *TrieWriter) Size() int {
 
 // Insert 向trie树中插入一个单词，返回当前对象。
 func (t *TrieWriter) Insert(word string) *TrieWriter {
-	node := t.tireRoot // 从trie树的根节点开始
-	wLen := 0
-	for _, v := range word { // 遍历单词中的每个字符
-		if t.skip.ShouldSkip(v) { // 如果该字符应该跳过，则继续下一个字符
+	t.insert([]byte(word), false)
+	return t
+}
+
+// InsertBoundary 向trie树中插入一个只在单词边界处匹配的单词，即使搜索器没有开启单词边界匹配，
+// 以字母或数字开头（结尾）的单词前（后）也不能紧挨着字母或数字，例如 "ass" 不会匹配 "class"，返回当前对象。
+func (t *TrieWriter) InsertBoundary(word string) *TrieWriter {
+	t.insert([]byte(word), true)
+	return t
+}
+
+// InsertContext 向trie树中插入一个带有上下文例外规则的单词，单词已经存在时追加规则。匹配到该单词后会检查其前后的文本，
+// 出现任意一条禁止规则的文本或者缺少任意一条必需规则的文本时丢弃该匹配，例如 "吸毒" 前面 2 个字符内出现 "禁止" 时不算敏感词，返回当前对象。
+func (t *TrieWriter) InsertContext(word string, rules ...ContextRule) *TrieWriter {
+	if node := t.insert([]byte(word), false); node != nil {
+		term := node.terminal()
+		for _, rule := range rules {
+			term.rules = append(term.rules, t.compileRule(rule))
+		}
+	}
+	return t
+}
+
+// insert 向trie树中插入一个单词，跳过字符会被忽略，其余字符归一化后写入trie树，strict表示是否只在单词边界处匹配。
+// 返回单词的结尾节点，单词为空或者插入的是白名单短语时返回nil。
+func (t *TrieWriter) insert(word []byte, strict bool) *trie {
+	runes, kept := t.normalize(word)
+	if len(runes) == 0 {
+		return nil
+	}
+	if t.allow { // 白名单短语只标记结尾节点，不会作为敏感词输出
+		t.insertAllow(runes, kept)
+		return nil
+	}
+	if t.pattern {
+		if node := t.insertPattern(word, strict); node != nil {
+			return node
+		}
+	}
+
+	node := t.insertRunes(runes)
+	if node.end && node.term.origin == nil { // 单词已经存在
+		node.term.strict = node.term.strict || strict
+		return node
+	}
+	node.end = true // 将结尾节点标记为end，并且数量加1，已经作为变体存在的单词也会被替换为原单词
+	node.term = &terminal{word: string(kept), strict: strict, seq: t.nextSeq()}
+	if len(kept) != len(word) { // 原文中有跳过字符时保留原文
+		node.term.meta = &Entry{Word: string(word)}
+	}
+	t.size++
+	if t.noise > 0 {
+		t.indexNoise(node, runes, t.noise)
+	}
+
+	if t.norm.pinyin { // 开启拼音匹配时，同时按拼音插入单词，匹配到时仍然返回原单词
+		for _, variant := range pinyinVariants(runes) {
+			t.insertVariant(variant, node, false)
+		}
+	}
+	if t.abbrMin > 0 { // 缩写和用户输入的字母一样需要归一化
+		for _, abbr := range abbreviations(runes, t.abbrMin) {
+			abbrRunes, _ := t.normalize([]byte(string(abbr)))
+			t.insertVariant(abbrRunes, node, true)
+		}
+	}
+	return node
+}
+
+// insertAllow 将归一化后的白名单短语 runes 插入到trie树中，kept 为去除跳过字符后的原始短语。
+func (t *TrieWriter) insertAllow(runes []rune, kept []byte) {
+	node := t.insertRunes(runes)
+	if node.allow == allowPhrase { // 短语已经存在
+		return
+	}
+	node.allow = allowPhrase
+	if term := node.terminal(); term.word == "" {
+		term.word = string(kept)
+	}
+	t.size++
+
+	t.insertPinyin(runes, func(n *trie) {
+		if n.allow == 0 {
+			n.allow = allowVariant
+		}
+	})
+}
+
+// insertPinyin 开启拼音匹配时将归一化后的字符序列 runes 按拼音插入到trie树中，并用 mark 标记每个拼音形式的结尾节点。
+// 搜索的文本中的汉字会展开为拼音，白名单短语、组合规则中的单词等不作为敏感词输出的字符序列同样需要按拼音插入才能匹配。
+func (t *TrieWriter) insertPinyin(runes []rune, mark func(node *trie)) {
+	if !t.norm.pinyin {
+		return
+	}
+	for _, variant := range pinyinVariants(runes) {
+		mark(t.insertRunes(variant))
+	}
+}
+
+// normalize 对单词做和搜索时一样的归一化处理，返回归一化后的字符序列以及去除跳过字符后的原始单词。
+func (t *TrieWriter) normalize(word []byte) (runes []rune, kept []byte) {
+	runes = make([]rune, 0, len(word))
+	kept = make([]byte, 0, len(word))
+	for i := 0; i < len(word); { // 遍历单词中的每个字符
+		raw, v, l := t.norm.next(word[i:]) // 解码并归一化
+		if t.norm.skip(t.skip, raw) {      // 如果该字符应该跳过，则继续下一个字符
+			i += l
 			continue
 		}
+		kept = append(kept, word[i:i+l]...)
+		runes = append(runes, v)
+		i += l
+	}
+	return
+}
+
+// insertVariant 将单词结尾节点 origin 对应单词的变体 runes 插入到trie树中，abbr表示变体是否是拼音首字母缩写，
+// 已经存在的单词或变体不会被覆盖。
+func (t *TrieWriter) insertVariant(runes []rune, origin *trie, abbr bool) {
+	if node := t.insertRunes(runes); !node.end {
+		node.end = true
+		node.term = &terminal{
+			word:   origin.term.word,
+			origin: origin,
+			abbr:   abbr,
+			strict: abbr && isASCII(runes), // 由字母组成的缩写只在单词边界处匹配
+		}
+	}
+}
+
+// insertRunes 沿着字符序列 runes 创建trie树节点，返回最后一个字符所在的节点。
+func (t *TrieWriter) insertRunes(runes []rune) *trie {
+	node := t.tireRoot // 从trie树的根节点开始
+	for i, v := range runes {
 		if _, ok := node.next[v]; !ok { // 如果下一个节点不存在，则创建一个新节点
 			node.next[v] = &trie{next: map[rune]*trie{}}
 		}
-		wLen += utf8.RuneLen(v) // 更新单词长度
 		node = node.next[v]
-		node.len = uint8(wLen)
-	}
-	if wLen > 0 && !node.end { // 如果单词不为空，将结尾节点标记为end，并且数量加1。
-		node.end = true
-		t.size++
+		node.len = i + 1 // 更新节点代表的字符串长度
 	}
-	return t
+	return node
 }
 
 // InsertWords 向trie树中插入一个字符串数组中的所有单词，返回当前对象。调用了Insert(word string)方法。
@@ -86,94 +302,97 @@ func (t *TrieWriter) trie() *trie {
 	return t.tireRoot
 }
 
-func (t *TrieWriter) InsertScanner(scanner *bufio.Scanner) {
+// InsertScanner 将 scanner 读取的每一行（或者按 scanner 的切分函数得到的每一段）写入到trie树中，
+// 被跳过的行记录在 Report 中，scanner 出错时返回 *LoadError
+func (t *TrieWriter) InsertScanner(scanner *bufio.Scanner) error {
+	report := t.beginLoad("")
 	for scanner.Scan() {
-		t.Insert(scanner.Text())
+		t.loadLine(scanner.Bytes())
 	}
+	if err := scanner.Err(); err != nil {
+		return &LoadError{Line: report.Lines + 1, Err: err}
+	}
+	return nil
+}
+
+// InsertFile 将文件中的单词（每行一个）写入到trie树中，被跳过的行记录在 Report 中，文件不能打开或者读取出错时返回 *LoadError
+func (t *TrieWriter) InsertFile(filename string) error {
+	return t.insertFile(filename, encoding.Nop)
 }
 
-func (t *TrieWriter) InsertFile(filename string) {
+// insertFile 将编码为 enc 的文件中的单词（每行一个）写入到trie树中
+func (t *TrieWriter) insertFile(filename string, enc encoding.Encoding) error {
 	file, err := os.Open(filename)
 	if err != nil {
-		panic(err)
+		return &LoadError{Source: filename, Err: err}
 	}
 	defer func() {
 		_ = file.Close()
 	}()
-	_, _ = t.InsertReader(file, '\n')
+	_, err = t.insertReader(transform.NewReader(file, newDecoder(enc)), make([]byte, 64*1024), '\n')
+	t.report.Source = filename
+	var loadErr *LoadError
+	if errors.As(err, &loadErr) {
+		loadErr.Source = filename
+	}
+	return err
 }
 
+// InsertReader 将 reader 中按分隔符 delim 切分的单词写入到trie树中，返回读取的字节数，
+// 被跳过的行记录在 Report 中，读取出错时返回 *LoadError
 func (t *TrieWriter) InsertReader(reader io.Reader, delim byte) (n int, err error) {
 	return t.insertReader(reader, make([]byte, 64*1024), delim)
 }
 
+// insertReader 使用缓冲区 buf 读取 reader 中的所有单词，一行比缓冲区还长时会扩大缓冲区，因此单词长度没有限制
 func (t *TrieWriter) insertReader(reader io.Reader, buf []byte, delim byte) (n int, err error) {
-	start, end, n1 := 0, 0, 0
+	report := t.beginLoad("")
+	end, n1 := 0, 0 // buf[:end] 为已经读取但还没有插入的数据
 	for {
-		n1, err = reader.Read(buf[start:])
+		if end == len(buf) { // 缓冲区中没有分隔符，扩大缓冲区
+			buf = append(buf, make([]byte, len(buf)+1)...)
+		}
+		n1, err = reader.Read(buf[end:])
+		n += n1
+		end += n1
 		if err == io.EOF {
-			t.InsertBytes(buf[:end], delim)
+			t.loadBytes(buf[:end], delim)
+			err = nil
 			break
 		}
 		if err != nil {
+			err = &LoadError{Line: report.Lines + 1, Err: err}
 			break
 		}
-		end = n1 + start
-		last := end
-		n += n1
-		if last < len(buf) {
-			t.InsertBytes(buf[:last], delim)
-			break
-		}
-		last = bytes.LastIndexByte(buf[:end], delim)
+		last := bytes.LastIndexByte(buf[:end], delim)
 		if last == -1 {
-			err = errors.New("word too long")
-			break
+			continue
 		}
-		t.InsertBytes(buf[:last], delim)
-		copy(buf, buf[last+1:end])
-		start = end - last - 1
-		end -= last + 1
+		t.loadBytes(buf[:last+1], delim)
+		end = copy(buf, buf[last+1:end])
 	}
 	return
 }
 
-// InsertBytes 将一个字节数组写入到trie树中，返回写入的字节数和nil错误。在遍历字节数组的过程中，跳过被定义在skip属性中的字符，如果遇到换行符则在该单词的结尾节点标记为end
+// InsertBytes 将一个字节数组按分隔符 delim 切分后写入到trie树中，返回写入的字节数。每个单词中被定义在skip属性中的字符会被跳过，
+// 被跳过的行记录在 Report 中。
 func (t *TrieWriter) InsertBytes(p []byte, delim byte) (n int) {
-	n = len(p)           // 获取字节数组的长度
-	for i := 0; i < n; { // 遍历字节数组
-		node := t.trie() // 从trie树的根节点开始
-		wLen := 0
-
-		for i < n && p[i] != delim { // 判断字符是否为换行符并且还没有遍历完整个字节数组
-			r, l := decodeBytes(p[i:]) // 解码字节数组中的一个rune，并且获取该rune的字节数量
-
-			// 跳过一些无意义的字符
-			if t.skip.ShouldSkip(r) {
-				i += l
-				continue
-			}
-
-			if _, ok := node.next[r]; !ok { // 如果下一个节点不存在，则创建一个新节点
-				node.next[r] = &trie{next: map[rune]*trie{}}
-			}
-			wLen += l // 更新单词长度
-			node = node.next[r]
-			node.len = uint8(wLen) // 更新节点代表的字符串长度
-			i += l                 // 向后移动光标
-		}
-
-		if wLen > 0 && !node.end { // 如果单词不为空，将结尾节点标记为end，并且数量加1。
-			node.end = true
-			t.size++
-		}
+	t.beginLoad("")
+	t.loadBytes(p, delim)
+	return len(p)
+}
 
-		for i < n && p[i] == delim { // 判断是否为分隔符
-			i++ // 向后移动光标
+// loadBytes 将 p 中按分隔符 delim 切分的每一行写入到trie树中，最后一个分隔符之后为空时不算作一行
+func (t *TrieWriter) loadBytes(p []byte, delim byte) {
+	for len(p) > 0 {
+		j := bytes.IndexByte(p, delim)
+		if j == -1 {
+			t.loadLine(p)
+			return
 		}
+		t.loadLine(p[:j])
+		p = p[j+1:]
 	}
-
-	return
 }
 
 // BuildFail 用于构建trie树中每个节点的失败指针，返回当前对象。
@@ -203,6 +422,11 @@ func (t *TrieWriter) BuildFail() int {
 				} else { // 如果找到了匹配字符c的节点，则将当前节点的失败指针设置为该节点
 					curr.fail = failTo.next[c]
 				}
+				if curr.fail.output() { // 失败指针所在层级更浅，其输出指针已经构建完成
+					curr.out = curr.fail
+				} else {
+					curr.out = curr.fail.out
+				}
 			}
 		}
 	}
@@ -210,61 +434,79 @@ func (t *TrieWriter) BuildFail() int {
 }
 
 func (t *TrieWriter) String() string {
-	type pair struct { // 定义一个键值对，trie表示trie树中的节点，runes表示从根节点到当前节点的字符序列
-		trie  *trie
-		runes []rune
-	}
-	buf := bytes.Buffer{}             // 创建缓冲区
-	limit := 1000                     // 设置限制输出结果的条数
-	queue := []*pair{{t.trie(), nil}} // 创建队列并将根节点加入队列中
+	buf := bytes.Buffer{}      // 创建缓冲区
+	limit := 1000              // 设置限制输出结果的条数
+	queue := []*trie{t.trie()} // 创建队列并将根节点加入队列中
 loop:
 	for len(queue) > 0 { // 遍历队列直到队列为空或者达到限制的输出结果数
-		temp := make([]*pair, len(queue)) // 创建临时队列并将队列中的元素复制到临时队列中
+		temp := make([]*trie, len(queue)) // 创建临时队列并将队列中的元素复制到临时队列中
 		copy(temp, queue)
-		queue = queue[:0]        // 清空队列
-		for _, p := range temp { // 遍历临时队列中的节点
-			if p.trie.end { // 如果当前节点是单词的结尾，则将字符序列转为字符串并添加到缓冲区中
-				buf.WriteString(string(p.runes))
+		queue = queue[:0]           // 清空队列
+		for _, curr := range temp { // 遍历临时队列中的节点
+			if t.listed(curr) { // 如果当前节点是单词的结尾，则将单词添加到缓冲区中
+				buf.WriteString(curr.term.word)
 				buf.WriteByte('\n')
 				limit--
 				if limit == 0 { // 如果达到限制的输出结果数，则跳出循环
 					break loop
 				}
 			}
-			for r, node := range p.trie.next { // 遍历当前节点的所有子节点
-				queue = append(queue, &pair{node, appendRunes(p.runes, r)}) // 将子节点加入队列，并更新字符序列
+			for _, node := range curr.next { // 遍历当前节点的所有子节点
+				queue = append(queue, node)
 			}
 		}
 	}
+	for _, p := range t.patternList() { // 模式单词不在trie树中
+		if limit == 0 {
+			break
+		}
+		buf.WriteString(p.node.term.word)
+		buf.WriteByte('\n')
+		limit--
+	}
 	return string(buf.Bytes()[:buf.Len()-1]) // 返回缓冲区中的字符串，去掉最后一个换行符
 }
 
-func appendRunes(runes []rune, r rune) []rune {
-	res := make([]rune, len(runes)+1)
-	copy(res, runes)
-	res[len(res)-1] = r
-	return res
+// nextSeq 返回下一个插入的单词的插入顺序
+func (t *TrieWriter) nextSeq() int {
+	t.seq++
+	return t.seq
 }
 
-func (t *TrieWriter) Array() []string {
-	type pair struct { // 定义一个键值对，trie表示trie树中的节点，runes表示从根节点到当前节点的字符序列
-		trie  *trie
-		runes []rune
+// patternList 返回按插入顺序排列的模式单词
+func (t *TrieWriter) patternList() []*pattern {
+	if t.patterns == nil {
+		return nil
+	}
+	return t.patterns.list
+}
+
+// listed 返回节点是否是当前TrieWriter写入的单词（白名单TrieWriter为白名单短语）的结尾节点，变体不算在内
+func (t *TrieWriter) listed(node *trie) bool {
+	if t.allow {
+		return node.allow == allowPhrase
 	}
+	return node.end && node.term.origin == nil
+}
+
+func (t *TrieWriter) Array() []string {
 	res := make([]string, 0, t.Size())
-	queue := []*pair{{t.trie(), nil}}
-	for len(queue) > 0 { // 遍历队列直到队列为空或者达到限制的输出结果数
-		temp := make([]*pair, len(queue)) // 创建临时队列并将队列中的元素复制到临时队列中
+	queue := []*trie{t.trie()}
+	for len(queue) > 0 { // 遍历队列直到队列为空
+		temp := make([]*trie, len(queue)) // 创建临时队列并将队列中的元素复制到临时队列中
 		copy(temp, queue)
-		queue = queue[:0]        // 清空队列
-		for _, p := range temp { // 遍历临时队列中的节点
-			if p.trie.end { // 如果当前节点是单词的结尾，则将字符序列转为字符串并添加到缓冲区中
-				res = append(res, string(p.runes))
+		queue = queue[:0]           // 清空队列
+		for _, curr := range temp { // 遍历临时队列中的节点
+			if t.listed(curr) { // 如果当前节点是单词的结尾，则将单词添加到结果中
+				res = append(res, curr.term.word)
 			}
-			for r, node := range p.trie.next { // 遍历当前节点的所有子节点
-				queue = append(queue, &pair{node, appendRunes(p.runes, r)}) // 将子节点加入队列，并更新字符序列
+			for _, node := range curr.next { // 遍历当前节点的所有子节点
+				queue = append(queue, node)
 			}
 		}
 	}
+	for _, p := range t.patternList() {
+		res = append(res, p.node.term.word)
+	}
 	return res
 }